@@ -0,0 +1,352 @@
+package main
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// Controller steers one snake. Next is called once per tick, before the
+// engine moves, and returns the direction the snake should head in; the
+// engine still gets the final say through changeDirection.
+type Controller interface {
+	Next(g *Game, player int) Point
+}
+
+var strategies = map[string]func() Controller{
+	"bfs":      func() Controller { return greedy{} },
+	"astar":    func() Controller { return astar{} },
+	"hamilton": func() Controller { return &hamiltonian{} },
+}
+
+var dirs = []Point{{0, -1}, {1, 0}, {0, 1}, {-1, 0}}
+
+const never = 1 << 30
+
+// board records when each cell can next be entered. A body segment i cells
+// from the head of a snake of length L is still there for the next L-i
+// moves, because the engine checks a new head against the whole body,
+// tail included, before anything moves.
+type board struct {
+	w, h   int
+	freeAt []int
+}
+
+func newBoard(g *Game, player int, body []Point) *board {
+	b := &board{w: g.width, h: g.height, freeAt: make([]int, g.width*g.height)}
+	for y := 0; y < g.height; y++ {
+		for x := 0; x < g.width; x++ {
+			if x == 0 || y == 0 || x == g.width-1 || y == g.height-1 {
+				b.freeAt[y*g.width+x] = never
+			}
+		}
+	}
+	for _, p := range g.walls {
+		b.freeAt[p.y*g.width+p.x] = never
+	}
+	for j, s := range g.snakes {
+		if j == player {
+			b.mark(body, s.dead)
+			continue
+		}
+		b.mark(s.body.points(), s.dead)
+	}
+	return b
+}
+
+func (b *board) mark(segments []Point, dead bool) {
+	for i, p := range segments {
+		at := len(segments) - i
+		if dead {
+			at = never
+		}
+		if c := p.y*b.w + p.x; at > b.freeAt[c] {
+			b.freeAt[c] = at
+		}
+	}
+}
+
+func (b *board) open(p Point, step int) bool {
+	return b.freeAt[p.y*b.w+p.x] < step
+}
+
+// search runs a breadth-first search from the head and returns the route to
+// the nearest cell satisfying goal, head excluded, along with how many
+// cells were reachable.
+func (b *board) search(from Point, goal func(Point) bool) ([]Point, int) {
+	parent := make([]int, b.w*b.h)
+	for i := range parent {
+		parent[i] = -1
+	}
+	start := from.y*b.w + from.x
+	parent[start] = start
+
+	dist := make([]int, b.w*b.h)
+	reached := 0
+	queue := []Point{from}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		step := dist[p.y*b.w+p.x] + 1
+		for _, d := range dirs {
+			n := Point{p.x + d.x, p.y + d.y}
+			c := n.y*b.w + n.x
+			if parent[c] >= 0 {
+				continue
+			}
+			if !b.open(n, step) {
+				continue
+			}
+			parent[c] = p.y*b.w + p.x
+			if goal != nil && goal(n) {
+				return b.trace(parent, start, c), reached
+			}
+			dist[c] = step
+			reached++
+			queue = append(queue, n)
+		}
+	}
+	return nil, reached
+}
+
+func (b *board) trace(parent []int, start, end int) []Point {
+	var path []Point
+	for c := end; c != start; c = parent[c] {
+		path = append(path, Point{c % b.w, c / b.w})
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+type node struct {
+	p          Point
+	cost, rank int
+}
+
+type frontier []node
+
+func (f frontier) Len() int            { return len(f) }
+func (f frontier) Less(i, j int) bool  { return f[i].rank < f[j].rank }
+func (f frontier) Swap(i, j int)       { f[i], f[j] = f[j], f[i] }
+func (f *frontier) Push(x interface{}) { *f = append(*f, x.(node)) }
+func (f *frontier) Pop() interface{} {
+	old := *f
+	n := old[len(old)-1]
+	*f = old[:len(old)-1]
+	return n
+}
+
+// astarPath finds the shortest route from the head to target using
+// Manhattan distance as the heuristic.
+func (b *board) astarPath(from, target Point) []Point {
+	parent := make([]int, b.w*b.h)
+	cost := make([]int, b.w*b.h)
+	for i := range parent {
+		parent[i] = -1
+	}
+	start := from.y*b.w + from.x
+	parent[start] = start
+
+	open := &frontier{{p: from, rank: manhattan(from, target)}}
+	for open.Len() > 0 {
+		cur := heap.Pop(open).(node)
+		if cur.p == target {
+			return b.trace(parent, start, target.y*b.w+target.x)
+		}
+		for _, d := range dirs {
+			n := Point{cur.p.x + d.x, cur.p.y + d.y}
+			c := n.y*b.w + n.x
+			step := cur.cost + 1
+			if !b.open(n, step) || (parent[c] >= 0 && cost[c] <= step) {
+				continue
+			}
+			parent[c] = cur.p.y*b.w + cur.p.x
+			cost[c] = step
+			heap.Push(open, node{p: n, cost: step, rank: step + manhattan(n, target)})
+		}
+	}
+	return nil
+}
+
+func manhattan(a, b Point) int {
+	dx, dy := a.x-b.x, a.y-b.y
+	if dx < 0 {
+		dx = -dx
+	}
+	if dy < 0 {
+		dy = -dy
+	}
+	return dx + dy
+}
+
+func towards(from, to Point) Point {
+	return Point{to.x - from.x, to.y - from.y}
+}
+
+// advance returns the body after the snake follows path, growing by one if
+// it eats at the end.
+func advance(body, path []Point, grow bool) []Point {
+	n := len(body)
+	if grow {
+		n++
+	}
+	moved := make([]Point, 0, len(path)+len(body))
+	for i := len(path) - 1; i >= 0; i-- {
+		moved = append(moved, path[i])
+	}
+	moved = append(moved, body...)
+	if len(moved) > n {
+		moved = moved[:n]
+	}
+	return moved
+}
+
+func tailPath(g *Game, player int, body []Point) []Point {
+	tail := body[len(body)-1]
+	path, _ := newBoard(g, player, body).search(body[0], func(p Point) bool { return p == tail })
+	return path
+}
+
+// safest picks the move that keeps the most of the board reachable, or
+// carries on straight when every move is fatal.
+func safest(g *Game, player int) Point {
+	s := g.snakes[player]
+	body := s.body.points()
+	head := body[0]
+	b := newBoard(g, player, body)
+	best, most := s.direction, -1
+	for _, d := range dirs {
+		n := Point{head.x + d.x, head.y + d.y}
+		if !b.open(n, 1) {
+			continue
+		}
+		_, area := newBoard(g, player, advance(body, []Point{n}, g.foodAt(n) >= 0)).search(n, nil)
+		if area > most {
+			best, most = d, area
+		}
+	}
+	return best
+}
+
+// greedy heads for the nearest food along the shortest path and only
+// thinks about survival when no food can be reached.
+type greedy struct{}
+
+func (greedy) Next(g *Game, player int) Point {
+	body := g.snakes[player].body.points()
+	path, _ := newBoard(g, player, body).search(body[0], func(p Point) bool { return g.foodAt(p) >= 0 })
+	if path != nil {
+		return towards(body[0], path[0])
+	}
+	return safest(g, player)
+}
+
+// astar only takes a route to food when, having eaten, it could still reach
+// its own tail. Otherwise it follows its tail the long way round until a
+// safe route opens up.
+type astar struct{}
+
+func (astar) Next(g *Game, player int) Point {
+	body := g.snakes[player].body.points()
+	head := body[0]
+	b := newBoard(g, player, body)
+
+	food := append([]Point(nil), g.food...)
+	sort.Slice(food, func(i, j int) bool { return manhattan(head, food[i]) < manhattan(head, food[j]) })
+	for _, f := range food {
+		path := b.astarPath(head, f)
+		if path == nil {
+			continue
+		}
+		after := advance(body, path, true)
+		if len(after) >= (g.width-2)*(g.height-2)-len(g.walls) || tailPath(g, player, after) != nil {
+			return towards(head, path[0])
+		}
+	}
+
+	best, longest := Point{}, -1
+	for _, d := range dirs {
+		n := Point{head.x + d.x, head.y + d.y}
+		if !b.open(n, 1) {
+			continue
+		}
+		if path := tailPath(g, player, advance(body, []Point{n}, g.foodAt(n) >= 0)); path != nil && len(path) > longest {
+			best, longest = d, len(path)
+		}
+	}
+	if longest >= 0 {
+		return best
+	}
+	return safest(g, player)
+}
+
+// hamiltonian walks a fixed cycle through every cell of the board. It's
+// slow, but it can't trap itself, so it always fills the board. Boards with
+// an odd number of rows and columns have no such cycle, and levels put walls
+// across it; there it falls back to astar.
+type hamiltonian struct {
+	w, h int
+	next []Point
+}
+
+func (c *hamiltonian) Next(g *Game, player int) Point {
+	if c.w != g.width || c.h != g.height {
+		c.build(g, g.snakes[player].body.points())
+	}
+	if c.next == nil || len(g.walls) > 0 {
+		return astar{}.Next(g, player)
+	}
+	head := g.snakes[player].body.front()
+	return towards(head, c.next[head.y*g.width+head.x])
+}
+
+func (c *hamiltonian) build(g *Game, body []Point) {
+	c.w, c.h, c.next = g.width, g.height, nil
+	w, h := g.width-2, g.height-2
+
+	var cycle []Point
+	switch {
+	case h%2 == 0 && w > 1:
+		cycle = zigzag(w, h, func(x, y int) Point { return Point{x + 1, y + 1} })
+	case w%2 == 0 && h > 1:
+		cycle = zigzag(h, w, func(x, y int) Point { return Point{y + 1, x + 1} })
+	default:
+		return
+	}
+
+	// Run the cycle in whichever direction the snake is already going.
+	c.next = make([]Point, g.width*g.height)
+	for i, p := range cycle {
+		c.next[p.y*g.width+p.x] = cycle[(i+1)%len(cycle)]
+	}
+	if len(body) > 1 && c.next[body[1].y*g.width+body[1].x] != body[0] {
+		for i, p := range cycle {
+			c.next[p.y*g.width+p.x] = cycle[(i+len(cycle)-1)%len(cycle)]
+		}
+	}
+}
+
+// zigzag lists a cycle over a w×h grid with h even: along the top row,
+// back and forth through the remaining columns, then up the first one.
+func zigzag(w, h int, at func(x, y int) Point) []Point {
+	cycle := make([]Point, 0, w*h)
+	for x := 0; x < w; x++ {
+		cycle = append(cycle, at(x, 0))
+	}
+	for y := 1; y < h; y++ {
+		if y%2 == 1 {
+			for x := w - 1; x >= 1; x-- {
+				cycle = append(cycle, at(x, y))
+			}
+		} else {
+			for x := 1; x < w; x++ {
+				cycle = append(cycle, at(x, y))
+			}
+		}
+	}
+	for y := h - 1; y >= 1; y-- {
+		cycle = append(cycle, at(0, y))
+	}
+	return cycle
+}
@@ -0,0 +1,61 @@
+package main
+
+// Batch advances many games with one Env's settings in lockstep, for
+// trainers that want a whole batch of experience per round trip. Every
+// game is an ordinary Game, so the rules, levels and food draws are the
+// ones local play has. A game that ends is restarted in place at the end
+// of the step, the way a finished match restarts, so the next step plays
+// its next episode.
+type Batch struct {
+	env   *Env
+	games []*Game
+
+	// Filled by Step for every game. Infos describe the step that was
+	// taken, so where Dones is set they hold how the episode ended.
+	Rewards []float64
+	Dones   []bool
+	Infos   []Info
+}
+
+// Batch sets up n games with e's settings. Game i starts from seed+i and
+// every later episode from a seed drawn from the one before.
+func (e *Env) Batch(n int, seed uint64) *Batch {
+	b := &Batch{
+		env:     e,
+		games:   make([]*Game, n),
+		Rewards: make([]float64, n),
+		Dones:   make([]bool, n),
+		Infos:   make([]Info, n),
+	}
+	for i := range b.games {
+		b.games[i] = &Game{foodCount: e.foodCount, seed: seed + uint64(i)}
+		b.games[i].init()
+	}
+	return b
+}
+
+// Step moves every game by one tick, game i taking actions[i] as Env.Step
+// would.
+func (b *Batch) Step(actions []int) {
+	for i, g := range b.games {
+		b.Rewards[i], b.Dones[i], b.Infos[i] = b.env.step(g, actions[i])
+		if b.Dones[i] {
+			g.init()
+		}
+	}
+}
+
+// Observe encodes every game the way the Env would, stacked along a new
+// first axis.
+func (b *Batch) Observe() Observation {
+	var out Observation
+	for i, g := range b.games {
+		o := b.env.encode(g)
+		if i == 0 {
+			out.Shape = append([]int{len(b.games)}, o.Shape...)
+			out.Data = make([]float32, 0, len(b.games)*len(o.Data))
+		}
+		out.Data = append(out.Data, o.Data...)
+	}
+	return out
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestBatchPlaysLikeGames(t *testing.T) {
+	const n, seed, food, maxTicks = 8, 11, 2, 60
+	env, err := NewEnv("features", defaultReward, food, maxTicks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bt := env.Batch(n, seed)
+	games := make([]*Game, n)
+	for i := range games {
+		games[i] = &Game{foodCount: food, seed: seed + uint64(i)}
+		games[i].init()
+	}
+
+	r := rand.New(rand.NewPCG(3, 4))
+	actions := make([]int, n)
+	episodes := 0
+	for step := 1; step <= 500; step++ {
+		// -1 and 4 are no action at all, which keeps the heading.
+		for i := range actions {
+			actions[i] = r.IntN(6) - 1
+		}
+		bt.Step(actions)
+		for i, g := range games {
+			if a := actions[i]; a >= 0 && a < len(dirs) {
+				g.changeDirection(0, dirs[a].x, dirs[a].y)
+			}
+			g.update()
+			done := g.gameOver || g.ticks >= maxTicks
+			if bt.Dones[i] != done || bt.Infos[i].Score != g.snakes[0].score || bt.Infos[i].Ticks != g.ticks {
+				t.Fatalf("step %d game %d: batch done %v with %+v, game done %v with score %d at tick %d",
+					step, i, bt.Dones[i], bt.Infos[i], done, g.snakes[0].score, g.ticks)
+			}
+			if done {
+				episodes++
+				g.init()
+			}
+			if got, want := bt.games[i].state(), g.state(); !reflect.DeepEqual(got, want) {
+				t.Fatalf("step %d game %d: batch has %+v, game has %+v", step, i, got, want)
+			}
+		}
+	}
+	if episodes < n {
+		t.Errorf("only %d episodes ended in 500 steps, want every game to have restarted", episodes)
+	}
+
+	obs := bt.Observe()
+	if !reflect.DeepEqual(obs.Shape, []int{n, 11}) {
+		t.Fatalf("observation shape %v, want [%d 11]", obs.Shape, n)
+	}
+	for i, g := range games {
+		if got, want := obs.Data[i*11:(i+1)*11], encodeFeatures(g).Data; !reflect.DeepEqual(got, want) {
+			t.Errorf("game %d observed as %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestEnvBatchOverSocket(t *testing.T) {
+	server, conn := net.Pipe()
+	go serveEnv(server, "features", defaultReward, 1, 0)
+	defer conn.Close()
+	enc, dec := json.NewEncoder(conn), json.NewDecoder(conn)
+	ask := func(req envRequest) envResponse {
+		t.Helper()
+		if err := enc.Encode(req); err != nil {
+			t.Fatal(err)
+		}
+		var resp envResponse
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := ask(envRequest{Cmd: "reset", Seed: 7, Games: 4})
+	if resp.Error != "" || resp.Obs == nil || !reflect.DeepEqual(resp.Obs.Shape, []int{4, 11}) {
+		t.Fatalf("batch reset answered %+v, want 4 observations of 11", resp)
+	}
+	if resp := ask(envRequest{Cmd: "step", Actions: []int{1, 1, 1}}); resp.Error == "" {
+		t.Error("a step with 3 actions for 4 games was taken")
+	}
+	resp = ask(envRequest{Cmd: "step", Actions: []int{0, 1, 2, 1}})
+	if resp.Error != "" || len(resp.Rewards) != 4 || len(resp.Dones) != 4 || len(resp.Infos) != 4 {
+		t.Fatalf("batch step answered %+v, want a reward, done and info per game", resp)
+	}
+	for i, info := range resp.Infos {
+		if info.Ticks != 1 {
+			t.Errorf("game %d is at tick %d after one step", i, info.Ticks)
+		}
+	}
+
+	// A plain reset goes back to a single game.
+	resp = ask(envRequest{Cmd: "reset", Seed: 7})
+	if !reflect.DeepEqual(resp.Obs.Shape, []int{11}) {
+		t.Errorf("plain reset after a batch observed %v, want [11]", resp.Obs.Shape)
+	}
+}
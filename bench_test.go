@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+)
+
+// benchActions is a fixed stream of moves that mostly carries straight on,
+// so benchmarks see a mix of long episodes and resets rather than snakes
+// dying every few ticks.
+func benchActions(n int, seed uint64) []int {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	actions := make([]int, n)
+	for i := range actions {
+		actions[i] = -1
+		if rng.IntN(8) == 0 {
+			actions[i] = rng.IntN(4)
+		}
+	}
+	return actions
+}
+
+// BenchmarkGame steps one Game at a time, the way Env does.
+func BenchmarkGame(b *testing.B) {
+	actions := benchActions(4096, 1)
+	g := &Game{foodCount: 1}
+	g.init()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if g.gameOver {
+			g.init()
+		}
+		if a := actions[i%len(actions)]; a >= 0 {
+			g.changeDirection(0, dirs[a].x, dirs[a].y)
+		}
+		g.update()
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "steps/s")
+}
+
+// longGame sets up a w×h board whose snake already fills nine tenths of
+// it, laid along the same cycle hamiltonian follows so it can keep going.
+func longGame(w, h int) (*Game, Controller) {
+	g := &Game{foodCount: 1}
+	g.init()
+	g.width, g.height = w, h
+	g.newRound()
+
+	cycle := zigzag(w-2, h-2, func(x, y int) Point { return Point{x + 1, y + 1} })
+	n := len(cycle) * 9 / 10
+	body := make([]Point, n)
+	for i := range body {
+		body[i] = cycle[n-1-i]
+	}
+	g.resetGrid()
+	g.food = g.food[:0]
+	g.snakes[0] = Snake{body: newRing(body, len(cycle)), direction: towards(body[1], body[0])}
+	for _, p := range body {
+		g.take(p, gridSnake)
+	}
+	g.spawnFood()
+	return g, &hamiltonian{}
+}
+
+// BenchmarkTick measures Game.update alone with a long snake on a big
+// board, steered round the cycle so it never dies.
+func BenchmarkTick(b *testing.B) {
+	const w, h = 202, 202
+	g, c := longGame(w, h)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if g.gameOver {
+			b.StopTimer()
+			g, c = longGame(w, h)
+			b.StartTimer()
+		}
+		d := c.Next(g, 0)
+		g.changeDirection(0, d.x, d.y)
+		g.update()
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "steps/s")
+}
+
+// BenchmarkBatch steps many boards at once and encodes them the way the
+// env socket does; one op is one lockstep step.
+func BenchmarkBatch(b *testing.B) {
+	for _, games := range []int{256, 4096} {
+		b.Run(fmt.Sprint(games), func(b *testing.B) {
+			stream := benchActions(games*64, 1)
+			env, err := NewEnv("features", defaultReward, 1, 0)
+			if err != nil {
+				b.Fatal(err)
+			}
+			bt := env.Batch(games, 1)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				off := (i % 64) * games
+				bt.Step(stream[off : off+games])
+				bt.Observe()
+			}
+			b.ReportMetric(float64(b.N)*float64(games)/b.Elapsed().Seconds(), "steps/s")
+		})
+	}
+}
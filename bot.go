@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// botProtocolVersion is the version of the stdin/stdout protocol spoken to
+// external bots, described in BOTS.md.
+const botProtocolVersion = 1
+
+const botHandshakeTimeout = 5 * time.Second
+
+type botHello struct {
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+	Width   int    `json:"width,omitempty"`
+	Height  int    `json:"height,omitempty"`
+	Name    string `json:"name,omitempty"`
+}
+
+type botState struct {
+	Type      string      `json:"type"`
+	Tick      int         `json:"tick"`
+	Width     int         `json:"width"`
+	Height    int         `json:"height"`
+	Snake     []wirePoint `json:"snake"`
+	Direction string      `json:"direction"`
+	Food      []wirePoint `json:"food"`
+	Score     int         `json:"score"`
+}
+
+type botEnd struct {
+	Type   string `json:"type"`
+	Result string `json:"result"`
+	Cause  string `json:"cause,omitempty"`
+	Score  int    `json:"score"`
+	Ticks  int    `json:"ticks"`
+}
+
+type botReply struct {
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	Tick    int    `json:"tick"`
+	Move    string `json:"move"`
+}
+
+// tailBuffer keeps the last few KB a bot wrote to stderr so a crash can be
+// explained.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > 4096 {
+		t.buf = t.buf[len(t.buf)-4096:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return strings.TrimSpace(string(t.buf))
+}
+
+// bot is a Controller backed by a subprocess. A bot that is too slow keeps
+// its current direction for that tick; one that exits or stops reading is
+// marked crashed and the game ends.
+type bot struct {
+	name     string
+	cmd      *exec.Cmd
+	stdin    *os.File
+	enc      *json.Encoder
+	replies  chan botReply
+	quit     chan struct{}
+	stderr   *tailBuffer
+	timeout  time.Duration
+	timeouts int
+	invalid  int
+	err      error
+}
+
+func startBot(command string, timeout time.Duration, g *Game) (*bot, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, errors.New("empty bot command")
+	}
+	b := &bot{
+		name:    args[0],
+		cmd:     exec.Command(args[0], args[1:]...),
+		replies: make(chan botReply, 16),
+		quit:    make(chan struct{}),
+		stderr:  &tailBuffer{},
+		timeout: timeout,
+	}
+	b.cmd.Stderr = b.stderr
+	// Its own process group lets stop kill whatever a wrapper script
+	// started too, and WaitDelay stops Wait from waiting on children that
+	// outlive the bot with its stderr still open.
+	b.cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	b.cmd.WaitDelay = time.Second
+	// A pipe of our own rather than StdinPipe, for write deadlines.
+	stdinR, stdin, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	b.cmd.Stdin = stdinR
+	stdout, err := b.cmd.StdoutPipe()
+	if err != nil {
+		stdinR.Close()
+		stdin.Close()
+		return nil, err
+	}
+	err = b.cmd.Start()
+	stdinR.Close()
+	if err != nil {
+		stdin.Close()
+		return nil, err
+	}
+	b.stdin = stdin
+	b.enc = json.NewEncoder(stdin)
+
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			var r botReply
+			if json.Unmarshal(scanner.Bytes(), &r) != nil {
+				r = botReply{Type: "invalid"}
+			}
+			select {
+			case b.replies <- r:
+			case <-b.quit:
+				return
+			}
+		}
+		close(b.replies)
+	}()
+
+	if err := b.handshake(g); err != nil {
+		b.stop()
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *bot) handshake(g *Game) error {
+	deadline := time.Now().Add(botHandshakeTimeout)
+	if err := b.send(botHello{Type: "hello", Version: botProtocolVersion, Width: g.width, Height: g.height}, deadline); err != nil {
+		return b.crashed(err)
+	}
+	select {
+	case r, ok := <-b.replies:
+		if !ok {
+			return b.crashed(errors.New("exited during handshake"))
+		}
+		if r.Type != "hello" {
+			return fmt.Errorf("bot answered hello with %q", r.Type)
+		}
+		if r.Version != botProtocolVersion {
+			return fmt.Errorf("bot speaks protocol version %d, need %d", r.Version, botProtocolVersion)
+		}
+		if r.Name != "" {
+			b.name = r.Name
+		}
+		return nil
+	case <-time.After(time.Until(deadline)):
+		return errors.New("bot did not answer hello")
+	}
+}
+
+// send writes m to the bot, giving up at deadline: a bot that has stopped
+// reading would otherwise hold the game up once the pipe fills.
+func (b *bot) send(m any, deadline time.Time) error {
+	b.stdin.SetWriteDeadline(deadline)
+	if err := b.enc.Encode(m); err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return errors.New("stopped reading its input")
+		}
+		return err
+	}
+	return nil
+}
+
+func (b *bot) crashed(err error) error {
+	if tail := b.stderr.String(); tail != "" {
+		err = fmt.Errorf("%w; stderr: %s", err, tail)
+	}
+	b.err = fmt.Errorf("bot %s crashed: %w", b.name, err)
+	return b.err
+}
+
+func (b *bot) Next(g *Game, player int) Point {
+	s := g.snakes[player]
+	if b.err != nil {
+		return s.direction
+	}
+
+	st := botState{
+		Type:      "state",
+		Tick:      g.ticks,
+		Width:     g.width,
+		Height:    g.height,
+		Direction: dirNames[s.direction],
+		Score:     s.score,
+	}
+	for i := 0; i < s.body.len(); i++ {
+		st.Snake = append(st.Snake, toWire(s.body.at(i)))
+	}
+	for _, f := range g.food {
+		st.Food = append(st.Food, toWire(f))
+	}
+	// Writing the state and reading the move share one timeout.
+	deadline := time.Now().Add(b.timeout)
+	if err := b.send(st, deadline); err != nil {
+		b.crashed(err)
+		return s.direction
+	}
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	for {
+		select {
+		case r, ok := <-b.replies:
+			if !ok {
+				b.crashed(errors.New("exited mid-game"))
+				return s.direction
+			}
+			if r.Type == "invalid" {
+				b.invalid++
+				continue
+			}
+			if r.Tick != g.ticks {
+				// A late answer to a tick that already timed out.
+				continue
+			}
+			d, ok := parseDir(r.Move)
+			if !ok {
+				b.invalid++
+				return s.direction
+			}
+			return d
+		case <-timer.C:
+			b.timeouts++
+			return s.direction
+		}
+	}
+}
+
+// stop closes the bot's stdin, gives it a moment to exit and then kills it
+// along with anything it started.
+func (b *bot) stop() {
+	close(b.quit)
+	b.stdin.Close()
+	done := make(chan struct{})
+	go func() {
+		b.cmd.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		syscall.Kill(-b.cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+	}
+}
+
+// finish tells the bot how the game went and stops it.
+func (b *bot) finish(g *Game, player int) {
+	s := g.snakes[player]
+	end := botEnd{Type: "end", Result: "death", Cause: s.cause, Score: s.score, Ticks: g.ticks}
+	if g.won {
+		end.Result = "victory"
+	}
+	if b.err == nil {
+		b.send(end, time.Now().Add(b.timeout))
+	}
+	b.stop()
+}
+
+// playSolo runs a single-player game with c steering until the game ends
+// or maxTicks pass, calling frame after every tick when it's set. A
+// crashed bot loses the game on the spot.
+func playSolo(g *Game, c Controller, maxTicks int, frame func()) {
+	b, _ := c.(*bot)
+	for !g.gameOver && (maxTicks <= 0 || g.ticks < maxTicks) {
+		d := c.Next(g, 0)
+		if b != nil && b.err != nil {
+			g.kill(0, causeCrash)
+		} else {
+			g.changeDirection(0, d.x, d.y)
+			g.update()
+		}
+		if frame != nil {
+			frame()
+		}
+	}
+	if b != nil {
+		b.finish(g, 0)
+	}
+}
+
+func runBot(args []string) {
+	fs := flag.NewFlagSet("bot", flag.ExitOnError)
+	command := fs.String("cmd", "", "bot `command` to run, e.g. \"python3 mybot.py\"")
+	timeout := fs.Duration("timeout", 100*time.Millisecond, "time a bot gets to answer each tick")
+	foodCount := fs.Int("food", 1, "number of food items on the board")
+	tick := fs.Duration("tick", 140*time.Millisecond, "time between rendered ticks")
+	headless := fs.Bool("headless", false, "don't draw the board, just run as fast as the bot can")
+	maxTicks := fs.Int("max-ticks", 0, "stop the game after this many ticks (0 for no limit)")
+	record := fs.String("record", "", "record the game to an asciinema cast `file`")
+	replayPath := fs.String("replay", "", "save a replay of the game to `file`, for gsnake export")
+	fs.Parse(args)
+
+	if *command == "" {
+		fmt.Fprintln(os.Stderr, "usage: gsnake bot --cmd COMMAND [flags]")
+		os.Exit(2)
+	}
+	if *foodCount < 1 || *timeout <= 0 || *tick <= 0 {
+		fmt.Fprintln(os.Stderr, "gsnake: --food, --timeout and --tick must be positive")
+		os.Exit(2)
+	}
+
+	g := &Game{foodCount: *foodCount, autopilot: "bot", seed: uint64(time.Now().UnixNano())}
+	if *replayPath != "" {
+		g.log = &replay{}
+	}
+	g.init()
+	b, err := startBot(*command, *timeout, g)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+	g.autopilot = b.name
+
+	var frame func()
+	if !*headless {
+		out, rec, err := recordTo(tty{}, *record, "gsnake: "+b.name, defaultWidth, defaultHeight)
+		if err != nil {
+			b.stop()
+			fmt.Fprintln(os.Stderr, "gsnake:", err)
+			os.Exit(1)
+		}
+		if rec != nil {
+			defer rec.Close()
+		}
+		frame = func() {
+			g.render(out)
+			time.Sleep(*tick)
+		}
+	}
+	playSolo(g, b, *maxTicks, frame)
+	if g.gameOver {
+		recordStats(g, modeBot)
+	}
+
+	s := g.snakes[0]
+	result := "died: " + causeText[s.cause]
+	switch {
+	case g.won:
+		result = "won"
+	case !g.gameOver:
+		result = "stopped at the tick limit"
+	}
+	fmt.Printf("%s scored %d in %d ticks (%s), %d timeouts, %d invalid moves\n",
+		b.name, s.score, g.ticks, result, b.timeouts, b.invalid)
+	if g.log != nil {
+		if err := g.log.save(*replayPath); err != nil {
+			fmt.Fprintln(os.Stderr, "gsnake:", err)
+			os.Exit(1)
+		}
+	}
+	if b.err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", b.err)
+		os.Exit(1)
+	}
+}
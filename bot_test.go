@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptBot writes a shell script that says hello and then does whatever
+// rest says, and returns the command that runs it.
+func scriptBot(t *testing.T, rest string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "bot.sh")
+	script := "#!/bin/sh\nread hello\necho '{\"type\":\"hello\",\"version\":1}'\n" + rest + "\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// within fails t unless f returns in d.
+func within(t *testing.T, d time.Duration, what string, f func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		f()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(d):
+		t.Fatalf("%s still going after %s", what, d)
+	}
+}
+
+func TestBotStopKillsWhatItStarted(t *testing.T) {
+	// sleep without exec outlives the shell if only the shell is killed,
+	// and holds its stderr open.
+	g := &Game{foodCount: 1}
+	g.init()
+	b, err := startBot(scriptBot(t, "sleep 30"), 100*time.Millisecond, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	within(t, 5*time.Second, "stopping the bot", b.stop)
+}
+
+func TestBotThatStopsReading(t *testing.T) {
+	// A state this big fills the pipe in a move or two.
+	g := &Game{width: maxBoardWidth, height: maxBoardHeight, foodCount: 8000}
+	g.init()
+	b, err := startBot(scriptBot(t, "sleep 30"), 100*time.Millisecond, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.stop()
+	within(t, 5*time.Second, "sending states to a bot that isn't reading", func() {
+		for i := 0; i < 10 && b.err == nil; i++ {
+			b.Next(g, 0)
+		}
+	})
+	if b.err == nil || !strings.Contains(b.err.Error(), "stopped reading") {
+		t.Errorf("bot error %v, want it to have stopped reading", b.err)
+	}
+}
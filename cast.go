@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// castHeader is the first line of an asciinema v2 cast file.
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// castRecorder is an io.Writer that turns every write into an output event
+// of an asciinema v2 cast, timed from when the recording started. The
+// renderer writes one frame per call, so each event is one frame.
+type castRecorder struct {
+	f     *os.File
+	start time.Time
+}
+
+func newCastRecorder(path, title string, width, height int) (*castRecorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &castRecorder{f: f, start: time.Now()}
+	header, _ := json.Marshal(castHeader{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: r.start.Unix(),
+		Title:     title,
+		Env:       map[string]string{"TERM": os.Getenv("TERM"), "SHELL": os.Getenv("SHELL")},
+	})
+	if _, err := f.Write(append(header, '\n')); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write records p as it would have reached the terminal. Raw mode leaves
+// output processing on, so the terminal turned every "\n" into "\r\n";
+// a player has no such help and needs it spelled out.
+func (r *castRecorder) Write(p []byte) (int, error) {
+	at := time.Since(r.start).Seconds()
+	data := strings.ReplaceAll(string(p), "\n", "\r\n")
+	event, err := json.Marshal([]interface{}{at, "o", data})
+	if err != nil {
+		return 0, err
+	}
+	if _, err := r.f.Write(append(event, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (r *castRecorder) Close() error {
+	return r.f.Close()
+}
+
+// castWidth and castHeight fit the standard board with the status line
+// above it and the game-over or spectator lines below. Bigger boards get
+// a bigger cast.
+const (
+	castWidth  = 80
+	castHeight = 30
+)
+
+// recordTo returns the writer frames should go to: term, and the cast
+// file at path as well when one is asked for. width and height are the
+// board's.
+func recordTo(term io.Writer, path, title string, width, height int) (io.Writer, *castRecorder, error) {
+	if path == "" {
+		return term, nil, nil
+	}
+	w := max(castWidth, width)
+	h := max(castHeight, height+castHeight-defaultHeight)
+	rec, err := newCastRecorder(path, title, w, h)
+	if err != nil {
+		return nil, nil, err
+	}
+	return io.MultiWriter(term, rec), rec, nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// client mirrors a server's game from the state and tick messages it
+// receives and draws it with the same renderer as local play.
+type client struct {
+	w       wire
+	id      int
+	addr    string
+	watcher bool
+	focus   int
+	game    *Game
+	lobby   *wireLobby
+	ready   bool
+	// term is where keys come from; out is where frames go, which is
+	// term, or term and a recording.
+	term Terminal
+	out  io.Writer
+}
+
+// dial connects to a gsnake serve at host:port, or over a WebSocket to
+// gsnake web when addr is a ws:// URL.
+func dial(addr, name, role string) (*client, error) {
+	var w wire
+	if strings.HasPrefix(addr, "ws://") {
+		ws, err := dialWS(addr)
+		if err != nil {
+			return nil, err
+		}
+		w = ws
+	} else {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		w = newLineConn(conn)
+	}
+	if err := w.send(&message{Type: msgHello, Version: protocolVersion, Name: name, Role: role}); err != nil {
+		w.close()
+		return nil, err
+	}
+	m, err := w.recv()
+	if err != nil {
+		w.close()
+		return nil, err
+	}
+	switch m.Type {
+	case msgWelcome:
+		return &client{w: w, id: m.ID, addr: addr, watcher: role == roleSpectator, term: tty{}, out: tty{}}, nil
+	case msgError:
+		w.close()
+		return nil, errors.New(m.Error)
+	default:
+		w.close()
+		return nil, fmt.Errorf("unexpected %q message from server", m.Type)
+	}
+}
+
+func (c *client) handle(m *message) {
+	switch m.Type {
+	case msgLobby:
+		c.game = nil
+		c.lobby = m.Lobby
+		c.ready = false
+		for _, p := range m.Lobby.Players {
+			if p.ID == c.id {
+				c.ready = p.Ready
+			}
+		}
+	case msgState:
+		c.game = gameFromState(m.State)
+		for i, s := range m.State.Snakes {
+			if s.ID == c.id {
+				c.game.names[i] += " (you)"
+			}
+		}
+	case msgTick:
+		if c.game != nil {
+			c.game.applyTick(m.Tick)
+		}
+	}
+}
+
+// render draws the game, or the lobby between matches, in one write.
+func (c *client) render() {
+	var out strings.Builder
+	defer func() { io.WriteString(c.out, out.String()) }()
+	if c.game != nil {
+		out.WriteString(c.game.frame())
+		if c.watcher {
+			fmt.Fprintln(&out, c.focusPanel())
+		}
+		return
+	}
+
+	out.WriteString("\033[H\033[2J")
+	fmt.Fprintf(&out, "gsnake lobby at %s\n\n", c.addr)
+	if c.lobby == nil {
+		return
+	}
+	ready := 0
+	for _, p := range c.lobby.Players {
+		mark := "·"
+		if p.Ready {
+			mark = "✔"
+			ready++
+		}
+		you := ""
+		if p.ID == c.id {
+			you = " (you)"
+		}
+		fmt.Fprintf(&out, "  %s %s%s\n", mark, p.Name, you)
+	}
+	fmt.Fprintf(&out, "\n%d/%d ready, %d needed to start", ready, len(c.lobby.Players), c.lobby.MinPlayers)
+	if c.lobby.Spectators > 0 {
+		fmt.Fprintf(&out, ", %d watching", c.lobby.Spectators)
+	}
+	fmt.Fprintln(&out)
+	if c.watcher {
+		fmt.Fprintf(&out, "Watching the lobby, %s to quit\n", keyName(keys.Quit))
+	} else {
+		fmt.Fprintf(&out, "Press %s to toggle ready or %s to quit\n", keyName(keys.Restart), keyName(keys.Quit))
+	}
+}
+
+func (c *client) play() error {
+	msgs := make(chan *message)
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			m, err := c.w.recv()
+			if err != nil {
+				errc <- err
+				return
+			}
+			msgs <- m
+		}
+	}()
+
+	inputs := make(chan input, 16)
+	go readInput(c.term, inputs)
+
+	for {
+		select {
+		case m := <-msgs:
+			c.handle(m)
+			c.render()
+		case err := <-errc:
+			return fmt.Errorf("connection lost: %w", err)
+		case in := <-inputs:
+			switch {
+			case c.watcher:
+				if c.watch(in) {
+					return nil
+				}
+				c.render()
+			case in.dir != (Point{}):
+				if c.game != nil {
+					c.w.send(&message{Type: msgDir, Dir: dirNames[in.dir]})
+				}
+			case in.key == 'q' || in.key == 'Q':
+				return nil
+			case in.key == 'r' || in.key == 'R':
+				if c.game == nil {
+					c.w.send(&message{Type: msgReady, Ready: !c.ready})
+				}
+			}
+		}
+	}
+}
+
+// watch handles a spectator's keys, which only ever change what this client
+// shows. It reports whether the spectator wants to leave.
+func (c *client) watch(in input) bool {
+	switch {
+	case in.dir == Point{1, 0}:
+		c.focus++
+	case in.dir == Point{-1, 0}:
+		c.focus--
+	case in.key >= '1' && in.key <= '4':
+		c.focus = int(in.key - '1')
+	case in.key == 'q' || in.key == 'Q':
+		return true
+	}
+	return false
+}
+
+func runJoin(args []string) {
+	cfg := mustLoadConfig()
+	cfg.use()
+	fs := flag.NewFlagSet("join", flag.ExitOnError)
+	name := fs.String("name", cfg.Name, "name shown to other players")
+	record := fs.String("record", "", "record what's drawn to an asciinema cast `file`")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: gsnake join [--name NAME] [--record FILE] host:port")
+		os.Exit(2)
+	}
+
+	c, err := dial(fs.Arg(0), *name, rolePlayer)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+	defer c.w.close()
+	out, rec, err := recordTo(c.term, *record, "gsnake at "+fs.Arg(0), defaultWidth, defaultHeight)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+	if rec != nil {
+		defer rec.Close()
+	}
+	c.out = out
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+
+	restore := c.term.Raw()
+	go func() {
+		<-sig
+		restore()
+		fmt.Println("\nGame terminated!")
+		os.Exit(0)
+	}()
+
+	err = c.play()
+	restore()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\ngsnake:", err)
+		os.Exit(1)
+	}
+	fmt.Println("\nThx for playing!")
+}
@@ -0,0 +1,552 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+)
+
+// Board sizes, walls included. The smallest still leaves room for four
+// snakes to start apart; the largest still fits a big terminal.
+const (
+	defaultWidth   = 40
+	defaultHeight  = 20
+	minBoardWidth  = 12
+	minBoardHeight = 8
+	maxBoardWidth  = 200
+	maxBoardHeight = 100
+)
+
+// keyBindings are the letter keys the game listens to. Up, Down, Left and
+// Right steer player 1 when alone and player 2 in a versus game; the arrow
+// keys always steer player 1.
+type keyBindings struct {
+	Up, Down, Left, Right                    byte
+	Pause, Save, Quit, Restart, Menu, Submit byte
+}
+
+func (k keyBindings) lower(b byte) byte {
+	if b >= 'A' && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+// action maps a bound key to the letter the game acts on, q, p, k, r, m or u. It
+// returns 0 for one of those letters when it has been rebound elsewhere,
+// and any other key as it is.
+func (k keyBindings) action(b byte) byte {
+	switch k.lower(b) {
+	case k.Quit:
+		return 'q'
+	case k.Pause:
+		return 'p'
+	case k.Save:
+		return 'k'
+	case k.Restart:
+		return 'r'
+	case k.Menu:
+		return 'm'
+	case k.Submit:
+		return 'u'
+	case 'q', 'p', 'k', 'r', 'm', 'u':
+		return 0
+	}
+	return b
+}
+
+// steering names the steering letters the way the status line shows them.
+func (k keyBindings) steering() string {
+	return strings.ToUpper(string([]byte{k.Up, k.Left, k.Down, k.Right}))
+}
+
+func keyName(b byte) string {
+	return strings.ToUpper(string(rune(b)))
+}
+
+// glyphSet is what the board is drawn with.
+type glyphSet struct {
+	Wall, Food, Body, Dead rune
+}
+
+// Config is every setting a player can change. It's built up in layers:
+// defaults, then the config file, then GSNAKE_* environment variables,
+// then command-line flags, each overriding the one before.
+type Config struct {
+	Width       int
+	Height      int
+	Tick        time.Duration
+	Food        int
+	Rounds      int
+	Mode        string
+	Autopilot   string
+	Level       string
+	Theme       string
+	Name        string
+	Leaderboard string
+	Keys        keyBindings
+	Glyphs      glyphSet
+
+	// source records which layer each setting last came from.
+	source map[string]string
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		Width:  defaultWidth,
+		Height: defaultHeight,
+		Tick:   140 * time.Millisecond,
+		Food:   1,
+		Rounds: 3,
+		Mode:   modeSolo,
+		Level:  "open",
+		Theme:  "classic",
+		Name:   os.Getenv("USER"),
+		Keys: keyBindings{
+			Up: 'w', Down: 's', Left: 'a', Right: 'd',
+			Pause: 'p', Save: 'k', Quit: 'q', Restart: 'r', Menu: 'm', Submit: 'u',
+		},
+		Glyphs: glyphSet{Wall: '█', Food: '♦', Body: '■', Dead: '✖'},
+		source: make(map[string]string),
+	}
+}
+
+// The keys and glyphs in use for this run.
+var (
+	keys   = defaultConfig().Keys
+	glyphs = defaultConfig().Glyphs
+)
+
+type setting struct {
+	key  string
+	help string
+	get  func(c *Config) string
+	set  func(c *Config, v string) error
+	// number is set for settings written to the file as JSON numbers.
+	number bool
+}
+
+func intSetting(key, help string, field func(c *Config) *int, lo, hi int) setting {
+	return setting{
+		key:    key,
+		help:   help,
+		number: true,
+		get:    func(c *Config) string { return strconv.Itoa(*field(c)) },
+		set: func(c *Config, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < lo || n > hi {
+				return fmt.Errorf("%s must be a whole number from %d to %d", key, lo, hi)
+			}
+			*field(c) = n
+			return nil
+		},
+	}
+}
+
+func keySetting(key, help string, field func(c *Config) *byte) setting {
+	return setting{
+		key:  key,
+		help: help,
+		get:  func(c *Config) string { return string(rune(*field(c))) },
+		set: func(c *Config, v string) error {
+			if len(v) != 1 || v[0] < '!' || v[0] > '~' {
+				return fmt.Errorf("%s must be a single printable key", key)
+			}
+			*field(c) = strings.ToLower(v)[0]
+			return nil
+		},
+	}
+}
+
+func glyphSetting(key, help string, field func(c *Config) *rune) setting {
+	return setting{
+		key:  key,
+		help: help,
+		get:  func(c *Config) string { return string(*field(c)) },
+		set: func(c *Config, v string) error {
+			r, size := utf8.DecodeRuneInString(v)
+			if r == utf8.RuneError || size != len(v) {
+				return fmt.Errorf("%s must be a single character", key)
+			}
+			*field(c) = r
+			return nil
+		},
+	}
+}
+
+func choiceSetting(key, help string, field func(c *Config) *string, ok func(string) bool) setting {
+	return setting{
+		key:  key,
+		help: help,
+		get:  func(c *Config) string { return *field(c) },
+		set: func(c *Config, v string) error {
+			if !ok(v) {
+				return fmt.Errorf("%s can't be %q", key, v)
+			}
+			*field(c) = v
+			return nil
+		},
+	}
+}
+
+var settings = []setting{
+	intSetting("width", "board width, walls included", func(c *Config) *int { return &c.Width }, minBoardWidth, maxBoardWidth),
+	intSetting("height", "board height, walls included", func(c *Config) *int { return &c.Height }, minBoardHeight, maxBoardHeight),
+	{
+		key:  "tick",
+		help: "time between game ticks, like 140ms",
+		get:  func(c *Config) string { return c.Tick.String() },
+		set: func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				return errors.New("tick must be a positive duration like 140ms")
+			}
+			c.Tick = d
+			return nil
+		},
+	},
+	intSetting("food", "number of food items on the board", func(c *Config) *int { return &c.Food }, 1, 100),
+	intSetting("rounds", "rounds per versus match (best of N)", func(c *Config) *int { return &c.Rounds }, 1, 99),
+	choiceSetting("mode", "solo or versus (two players on one keyboard)", func(c *Config) *string { return &c.Mode },
+		func(v string) bool { return v == modeSolo || v == modeVersus }),
+	choiceSetting("autopilot", "let player 1 play itself: bfs, astar or hamilton, empty for off", func(c *Config) *string { return &c.Autopilot },
+		func(v string) bool { _, ok := strategies[v]; return v == "" || ok }),
+	choiceSetting("level", "obstacle layout: "+levelNames(), func(c *Config) *string { return &c.Level },
+		func(v string) bool { _, ok := levels[v]; return ok }),
+	choiceSetting("theme", "color theme: "+themeNames(), func(c *Config) *string { return &c.Theme },
+		func(v string) bool { _, ok := themes[v]; return ok }),
+	choiceSetting("name", "your name in network games and on leaderboards", func(c *Config) *string { return &c.Name },
+		func(v string) bool { return len(v) <= 32 }),
+	choiceSetting("leaderboard", "`url` of a gsnake leaderboard-server, empty to keep scores on this machine", func(c *Config) *string { return &c.Leaderboard },
+		func(v string) bool {
+			u, err := url.Parse(v)
+			return v == "" || err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+		}),
+	keySetting("keys.up", "letter key for up", func(c *Config) *byte { return &c.Keys.Up }),
+	keySetting("keys.down", "letter key for down", func(c *Config) *byte { return &c.Keys.Down }),
+	keySetting("keys.left", "letter key for left", func(c *Config) *byte { return &c.Keys.Left }),
+	keySetting("keys.right", "letter key for right", func(c *Config) *byte { return &c.Keys.Right }),
+	keySetting("keys.pause", "pause key", func(c *Config) *byte { return &c.Keys.Pause }),
+	keySetting("keys.save", "save key", func(c *Config) *byte { return &c.Keys.Save }),
+	keySetting("keys.quit", "quit key", func(c *Config) *byte { return &c.Keys.Quit }),
+	keySetting("keys.restart", "restart key", func(c *Config) *byte { return &c.Keys.Restart }),
+	keySetting("keys.menu", "key that goes back to the menu once a round is over", func(c *Config) *byte { return &c.Keys.Menu }),
+	keySetting("keys.submit", "key that sends a finished game to the leaderboard", func(c *Config) *byte { return &c.Keys.Submit }),
+	glyphSetting("glyphs.wall", "character walls are drawn with", func(c *Config) *rune { return &c.Glyphs.Wall }),
+	glyphSetting("glyphs.food", "character food is drawn with", func(c *Config) *rune { return &c.Glyphs.Food }),
+	glyphSetting("glyphs.body", "character snakes are drawn with", func(c *Config) *rune { return &c.Glyphs.Body }),
+	glyphSetting("glyphs.dead", "character a dead snake's head is drawn with", func(c *Config) *rune { return &c.Glyphs.Dead }),
+}
+
+func findSetting(key string) *setting {
+	for i := range settings {
+		if settings[i].key == key {
+			return &settings[i]
+		}
+	}
+	return nil
+}
+
+func configPath() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gsnake", "config")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "config"
+	}
+	return filepath.Join(home, ".config", "gsnake", "config")
+}
+
+// envName is the environment variable that overrides key, e.g.
+// GSNAKE_KEYS_UP for keys.up.
+func envName(key string) string {
+	return "GSNAKE_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// flatten turns the nested JSON object of a config file into dotted keys,
+// so {"keys": {"up": "i"}} becomes keys.up = i.
+func flatten(prefix string, obj map[string]json.RawMessage, out map[string]string) error {
+	for k, raw := range obj {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		raw = bytes.TrimSpace(raw)
+		switch {
+		case len(raw) > 0 && raw[0] == '{':
+			var inner map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &inner); err != nil {
+				return err
+			}
+			if err := flatten(key, inner, out); err != nil {
+				return err
+			}
+		case len(raw) > 0 && raw[0] == '"':
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return err
+			}
+			out[key] = s
+		default:
+			out[key] = string(raw)
+		}
+	}
+	return nil
+}
+
+// readConfigFile returns the settings in the config file as dotted keys,
+// or none when there is no file.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	values := make(map[string]string)
+	if err := flatten("", obj, values); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+// loadConfig layers the config file and the environment over the defaults.
+// Keys it doesn't know are returned rather than treated as errors, so an
+// old build can still read a newer file; bad values are errors.
+func loadConfig() (c *Config, unknown []string, errs []error) {
+	c = defaultConfig()
+	for _, s := range settings {
+		c.source[s.key] = "default"
+	}
+
+	values, err := readConfigFile(configPath())
+	if err != nil {
+		return c, nil, []error{err}
+	}
+	for key, v := range values {
+		s := findSetting(key)
+		if s == nil {
+			unknown = append(unknown, key)
+			continue
+		}
+		if err := s.set(c, v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", configPath(), err))
+			continue
+		}
+		c.source[key] = "file"
+	}
+	sort.Strings(unknown)
+
+	for _, s := range settings {
+		v, ok := os.LookupEnv(envName(s.key))
+		if !ok {
+			continue
+		}
+		if err := s.set(c, v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", envName(s.key), err))
+			continue
+		}
+		c.source[s.key] = "env"
+	}
+	if err := c.check(); err != nil {
+		errs = append(errs, err)
+	}
+	return c, unknown, errs
+}
+
+// check catches what no single setting can: one key bound to two things.
+func (c *Config) check() error {
+	bound := make(map[byte]string)
+	for _, s := range settings {
+		if !strings.HasPrefix(s.key, "keys.") {
+			continue
+		}
+		k := s.get(c)[0]
+		if other, ok := bound[k]; ok {
+			return fmt.Errorf("%s and %s are both bound to %s", other, s.key, keyName(k))
+		}
+		bound[k] = s.key
+	}
+	return nil
+}
+
+// saveConfig writes the settings named by changed to the config file as
+// they are in c, leaving everything else in the file alone, unknown keys
+// included.
+func saveConfig(c *Config, changed ...string) error {
+	path := configPath()
+	obj := make(map[string]any)
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+
+	for _, key := range changed {
+		s := findSetting(key)
+		var v any = s.get(c)
+		if s.number {
+			v = json.Number(s.get(c))
+		}
+		parts := strings.Split(key, ".")
+		m := obj
+		for _, part := range parts[:len(parts)-1] {
+			inner, ok := m[part].(map[string]any)
+			if !ok {
+				inner = make(map[string]any)
+				m[part] = inner
+			}
+			m = inner
+		}
+		m[parts[len(parts)-1]] = v
+		c.source[key] = "file"
+	}
+
+	data, err = json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// fromFlags reports whether any setting was given on the command line.
+func (c *Config) fromFlags() bool {
+	for _, source := range c.source {
+		if source == "flag" {
+			return true
+		}
+	}
+	return false
+}
+
+// settingValue lets a setting be set from a flag, showing its effective
+// value from the lower layers as the default.
+type settingValue struct {
+	s *setting
+	c *Config
+}
+
+func (v settingValue) String() string {
+	if v.c == nil {
+		return ""
+	}
+	return v.s.get(v.c)
+}
+
+func (v settingValue) Set(value string) error {
+	if err := v.s.set(v.c, value); err != nil {
+		return err
+	}
+	v.c.source[v.s.key] = "flag"
+	return nil
+}
+
+// addConfigFlags gives fs a flag for every setting, named after its key
+// with dots as dashes (--keys-up), plus --players as the older way of
+// picking the mode.
+func addConfigFlags(fs *flag.FlagSet, c *Config) {
+	for i := range settings {
+		s := &settings[i]
+		fs.Var(settingValue{s, c}, strings.ReplaceAll(s.key, ".", "-"), s.help)
+	}
+	fs.Func("players", "number of players sharing the keyboard (1 or 2), same as --mode", func(v string) error {
+		switch v {
+		case "1":
+			c.Mode = modeSolo
+		case "2":
+			c.Mode = modeVersus
+		default:
+			return errors.New("must be 1 or 2")
+		}
+		c.source["mode"] = "flag"
+		return nil
+	})
+}
+
+// mustLoadConfig is loadConfig for commands that can't go on with a broken
+// config.
+func mustLoadConfig() *Config {
+	c, _, errs := loadConfig()
+	if len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintln(os.Stderr, "gsnake:", err)
+		}
+		fmt.Fprintln(os.Stderr, "gsnake: see gsnake config validate")
+		os.Exit(2)
+	}
+	return c
+}
+
+// use makes c the settings this run plays with.
+func (c *Config) use() {
+	theme = themes[c.Theme]
+	keys = c.Keys
+	glyphs = c.Glyphs
+}
+
+func runConfig(args []string) {
+	if len(args) == 0 || (args[0] != "show" && args[0] != "validate") {
+		fmt.Fprintln(os.Stderr, "usage: gsnake config show [flags] | gsnake config validate")
+		os.Exit(2)
+	}
+	c, unknown, errs := loadConfig()
+
+	if args[0] == "validate" {
+		path := configPath()
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("%s: no config file, using defaults\n", path)
+		}
+		for _, key := range unknown {
+			fmt.Printf("%s: unknown key %q\n", path, key)
+		}
+		for _, err := range errs {
+			fmt.Println(err)
+		}
+		if len(unknown) > 0 || len(errs) > 0 {
+			os.Exit(1)
+		}
+		fmt.Println("config ok")
+		return
+	}
+
+	fs := flag.NewFlagSet("config show", flag.ExitOnError)
+	addConfigFlags(fs, c)
+	fs.Parse(args[1:])
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+	}
+	for _, key := range unknown {
+		fmt.Fprintf(os.Stderr, "gsnake: %s: unknown key %q\n", configPath(), key)
+	}
+
+	fmt.Printf("# %s\n", configPath())
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, s := range settings {
+		fmt.Fprintf(w, "%s\t%s\t(%s)\n", s.key, strconv.Quote(s.get(c)), c.source[s.key])
+	}
+	w.Flush()
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// dailyTick is the speed every daily challenge is played at, whatever the
+// player's own settings, so a score means the same for everyone.
+const dailyTick = 140 * time.Millisecond
+
+// dailyChallenge is what a UTC date turns into. Everyone playing the same
+// day starts from the same seed on the same variant.
+type dailyChallenge struct {
+	Date  string
+	Seed  uint64
+	Level string
+	Food  int
+}
+
+func dailyFor(day time.Time) dailyChallenge {
+	date := day.UTC().Format(time.DateOnly)
+	sum := sha256.Sum256([]byte("gsnake daily " + date))
+	variant := binary.BigEndian.Uint64(sum[8:16])
+	return dailyChallenge{
+		Date:  date,
+		Seed:  binary.BigEndian.Uint64(sum[:8]),
+		Level: levelOrder[variant%uint64(len(levelOrder))],
+		Food:  1 + int((variant>>8)%3),
+	}
+}
+
+// dailyResult is one player's attempt at one day's challenge, signed by the
+// key of whoever played it.
+type dailyResult struct {
+	Date   string            `json:"date"`
+	Name   string            `json:"name"`
+	Score  int               `json:"score"`
+	Length int               `json:"length"`
+	Ticks  int               `json:"ticks"`
+	Key    ed25519.PublicKey `json:"key"`
+	Sig    []byte            `json:"sig,omitempty"`
+}
+
+// payload is what gets signed: the result without its signature.
+func (r dailyResult) payload() []byte {
+	r.Sig = nil
+	data, _ := json.Marshal(r)
+	return data
+}
+
+func (r dailyResult) verify() bool {
+	return len(r.Key) == ed25519.PublicKeySize && ed25519.Verify(r.Key, r.payload(), r.Sig)
+}
+
+// fingerprint is a short name for the key a result was signed with, for
+// telling apart two players who picked the same name.
+func (r dailyResult) fingerprint() string {
+	sum := sha256.Sum256(r.Key)
+	return hex.EncodeToString(sum[:4])
+}
+
+const sharePrefix = "gsnake-daily."
+
+// share is the result as one line to paste to others: the signed payload
+// and the signature, both base64.
+func (r dailyResult) share() string {
+	enc := base64.RawURLEncoding
+	return sharePrefix + enc.EncodeToString(r.payload()) + "." + enc.EncodeToString(r.Sig)
+}
+
+// parseShare reads a string made by share and checks its signature. A good
+// signature means the result is as its key holder published it; it says
+// nothing about whether the game was played fairly.
+func parseShare(s string) (dailyResult, error) {
+	var r dailyResult
+	payload, sig, ok := strings.Cut(strings.TrimPrefix(strings.TrimSpace(s), sharePrefix), ".")
+	if !ok || !strings.HasPrefix(strings.TrimSpace(s), sharePrefix) {
+		return r, errors.New("not a gsnake daily result")
+	}
+	enc := base64.RawURLEncoding
+	data, err := enc.DecodeString(payload)
+	if err != nil {
+		return r, errors.New("damaged daily result")
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, errors.New("damaged daily result")
+	}
+	if r.Sig, err = enc.DecodeString(sig); err != nil {
+		return r, errors.New("damaged daily result")
+	}
+	if !r.verify() {
+		return r, errors.New("signature doesn't match, the result was changed or made up")
+	}
+	return r, nil
+}
+
+func dailyKeyPath() string {
+	return filepath.Join(dataDir(), "daily.key")
+}
+
+// dailyKey returns this player's signing key, making one the first time.
+func dailyKey() (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(dailyKeyPath())
+	if err == nil {
+		seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("%s: damaged key", dailyKeyPath())
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dataDir(), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(dailyKeyPath(), []byte(hex.EncodeToString(key.Seed())+"\n"), 0o600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// dailyBoard is the local daily leaderboard: this player's own attempts
+// and every result from others that has been checked with --verify.
+type dailyBoard struct {
+	Results []dailyResult `json:"results"`
+}
+
+func dailyBoardPath() string {
+	return filepath.Join(dataDir(), "daily.json")
+}
+
+func loadDailyBoard() (*dailyBoard, error) {
+	b := &dailyBoard{}
+	data, err := os.ReadFile(dailyBoardPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("%s: %w", dailyBoardPath(), err)
+	}
+	return b, nil
+}
+
+func (b *dailyBoard) save() error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dataDir(), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(dailyBoardPath(), data, 0o644)
+}
+
+// find returns the result the given key has on file for date, if any.
+func (b *dailyBoard) find(date string, key ed25519.PublicKey) *dailyResult {
+	for i := range b.Results {
+		if r := &b.Results[i]; r.Date == date && r.Key.Equal(key) {
+			return r
+		}
+	}
+	return nil
+}
+
+// put files r, replacing what its key had for that day.
+func (b *dailyBoard) put(r dailyResult) {
+	if old := b.find(r.Date, r.Key); old != nil {
+		*old = r
+		return
+	}
+	b.Results = append(b.Results, r)
+}
+
+func (b *dailyBoard) print(date string, you ed25519.PublicKey) {
+	var day []dailyResult
+	for _, r := range b.Results {
+		if r.Date == date {
+			day = append(day, r)
+		}
+	}
+	if len(day) == 0 {
+		fmt.Println("no results for", date)
+		return
+	}
+	sort.SliceStable(day, func(i, j int) bool {
+		if day[i].Score != day[j].Score {
+			return day[i].Score > day[j].Score
+		}
+		return day[i].Ticks < day[j].Ticks
+	})
+	t, _ := time.Parse(time.DateOnly, date)
+	ch := dailyFor(t)
+	fmt.Printf("daily challenge %s: %s level, %d food\n", date, ch.Level, ch.Food)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, r := range day {
+		mark := ""
+		if r.Key.Equal(you) {
+			mark = " (you)"
+		}
+		fmt.Fprintf(w, "%d.\t%s%s\t%d\t%d ticks\t%s\n", i+1, r.Name, mark, r.Score, r.Ticks, r.fingerprint())
+	}
+	w.Flush()
+}
+
+func dailyReplayPath(date string) string {
+	return filepath.Join(dataDir(), "daily", date+".replay")
+}
+
+// playDaily plays today's challenge on term and returns the signed result.
+// The attempt is on file from the moment it starts, so quitting early or
+// killing the game doesn't earn a second try.
+func playDaily(term Terminal, ch dailyChallenge, cfg *Config, key ed25519.PrivateKey, board *dailyBoard, lb leaderboard) (dailyResult, error) {
+	result := dailyResult{Date: ch.Date, Name: cfg.Name, Key: key.Public().(ed25519.PublicKey)}
+	if result.Name == "" {
+		result.Name = "anonymous"
+	}
+	sign := func(g *Game) error {
+		if g != nil {
+			s := g.snakes[0]
+			result.Score, result.Length, result.Ticks = s.score, s.body.len(), g.ticks
+		}
+		result.Sig = ed25519.Sign(key, result.payload())
+		board.put(result)
+		return board.save()
+	}
+	if err := sign(nil); err != nil {
+		return result, err
+	}
+
+	game := Game{
+		width:     defaultWidth,
+		height:    defaultHeight,
+		players:   1,
+		foodCount: ch.Food,
+		level:     ch.Level,
+		seed:      ch.Seed,
+		daily:     ch.Date,
+		log:       &replay{},
+	}
+	game.init()
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	restore := term.Raw()
+	inputs := make(chan input, 16)
+	go readInput(term, inputs)
+	go func() {
+		<-c
+		restore()
+		fmt.Println("\nGame terminated! That was today's attempt")
+		os.Exit(0)
+	}()
+	session := &localSession{inputs: inputs, out: term, board: lb, name: result.Name}
+	session.play(&game, dailyTick)
+	restore()
+
+	if err := os.MkdirAll(filepath.Dir(dailyReplayPath(ch.Date)), 0o755); err == nil {
+		game.log.save(dailyReplayPath(ch.Date))
+	}
+	return result, sign(&game)
+}
+
+func runDaily(args []string) {
+	fs := flag.NewFlagSet("daily", flag.ExitOnError)
+	verify := fs.String("verify", "", "check a `result` someone shared and add it to the daily leaderboard")
+	show := fs.Bool("board", false, "show the daily leaderboard instead of playing")
+	date := fs.String("date", "", "with --board, the `day` to show, YYYY-MM-DD in UTC (default today)")
+	fs.Parse(args)
+
+	board, err := loadDailyBoard()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+	key, err := dailyKey()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+	you := key.Public().(ed25519.PublicKey)
+	today := dailyFor(time.Now())
+
+	switch {
+	case *verify != "":
+		r, err := parseShare(*verify)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "gsnake:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("good signature: %s scored %d in %d ticks on %s (key %s)\n", r.Name, r.Score, r.Ticks, r.Date, r.fingerprint())
+		if r.Key.Equal(you) {
+			return
+		}
+		board.put(r)
+		if err := board.save(); err != nil {
+			fmt.Fprintln(os.Stderr, "gsnake:", err)
+			os.Exit(1)
+		}
+		return
+	case *show:
+		day := today.Date
+		if *date != "" {
+			t, err := time.Parse(time.DateOnly, *date)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "gsnake: --date wants a day like 2026-01-31")
+				os.Exit(2)
+			}
+			day = t.Format(time.DateOnly)
+		}
+		board.print(day, you)
+		return
+	}
+
+	if r := board.find(today.Date, you); r != nil {
+		fmt.Printf("you've had your go at %s's challenge: %d points in %d ticks\n", today.Date, r.Score, r.Ticks)
+		fmt.Println("a new one starts at midnight UTC")
+	} else {
+		cfg := mustLoadConfig()
+		cfg.use()
+		lb, err := openLeaderboard(cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "gsnake:", err)
+			os.Exit(1)
+		}
+		r, err := playDaily(tty{}, today, cfg, key, board, lb)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "gsnake:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("\n%s's challenge: %d points in %d ticks\n", today.Date, r.Score, r.Ticks)
+	}
+	r := board.find(today.Date, you)
+	fmt.Println("\nshare it, gsnake daily --verify checks it:")
+	fmt.Println(r.share())
+	fmt.Println()
+	board.print(today.Date, you)
+}
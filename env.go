@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+)
+
+// Reward sets what an Env pays out for each thing that can happen in a
+// step. Death and Step are usually negative.
+type Reward struct {
+	Food  float64 `json:"food"`
+	Death float64 `json:"death"`
+	Step  float64 `json:"step"`
+	Win   float64 `json:"win"`
+}
+
+var defaultReward = Reward{Food: 1, Death: -1, Step: -0.01, Win: 10}
+
+// maxBatch is the most games one connection can step at once.
+const maxBatch = 65536
+
+// Observation is a flat float32 tensor plus its shape, row-major.
+type Observation struct {
+	Shape []int     `json:"shape"`
+	Data  []float32 `json:"data"`
+}
+
+// Info carries what a step did besides the reward. Truncated is set when
+// the episode was cut off by the tick limit rather than ended by the game.
+type Info struct {
+	Score     int    `json:"score"`
+	Length    int    `json:"length"`
+	Ticks     int    `json:"ticks"`
+	Cause     string `json:"cause,omitempty"`
+	Won       bool   `json:"won"`
+	Truncated bool   `json:"truncated"`
+}
+
+var encoders = map[string]func(g *Game) Observation{
+	"grid":     encodeGrid,
+	"rays":     encodeRays,
+	"features": encodeFeatures,
+}
+
+// Env wraps a single-player Game in a gym-style Reset/Step API. Actions are
+// absolute directions: 0 up, 1 right, 2 down, 3 left.
+type Env struct {
+	game      *Game
+	foodCount int
+	maxTicks  int
+	reward    Reward
+	encode    func(g *Game) Observation
+}
+
+func NewEnv(obs string, reward Reward, foodCount, maxTicks int) (*Env, error) {
+	encode, ok := encoders[obs]
+	if !ok {
+		return nil, fmt.Errorf("unknown observation encoding %q", obs)
+	}
+	if foodCount < 1 {
+		return nil, errors.New("food count must be at least 1")
+	}
+	return &Env{foodCount: foodCount, maxTicks: maxTicks, reward: reward, encode: encode}, nil
+}
+
+func (e *Env) Reset(seed uint64) Observation {
+	e.game = &Game{foodCount: e.foodCount, seed: seed}
+	e.game.init()
+	return e.encode(e.game)
+}
+
+func (e *Env) Step(action int) (Observation, float64, bool, Info) {
+	reward, done, info := e.step(e.game, action)
+	return e.encode(e.game), reward, done, info
+}
+
+// step plays action on g for one tick and scores it by e's rewards.
+func (e *Env) step(g *Game, action int) (float64, bool, Info) {
+	if action >= 0 && action < len(dirs) {
+		g.changeDirection(0, dirs[action].x, dirs[action].y)
+	}
+
+	reward := e.reward.Step
+	for _, ev := range g.update() {
+		switch ev {
+		case EventFood:
+			reward += e.reward.Food
+		case EventDeath:
+			reward += e.reward.Death
+		case EventVictory:
+			reward += e.reward.Win
+		}
+	}
+
+	s := g.snakes[0]
+	info := Info{Score: s.score, Length: s.body.len(), Ticks: g.ticks, Cause: s.cause, Won: g.won}
+	done := g.gameOver
+	if !done && e.maxTicks > 0 && g.ticks >= e.maxTicks {
+		done = true
+		info.Truncated = true
+	}
+	return reward, done, info
+}
+
+// encodeGrid is the whole board as four planes: walls, body, head and food.
+func encodeGrid(g *Game) Observation {
+	plane := g.width * g.height
+	data := make([]float32, 4*plane)
+	for y := 0; y < g.height; y++ {
+		for x := 0; x < g.width; x++ {
+			if x == 0 || y == 0 || x == g.width-1 || y == g.height-1 {
+				data[y*g.width+x] = 1
+			}
+		}
+	}
+	s := g.snakes[0]
+	for i := 0; i < s.body.len(); i++ {
+		p := s.body.at(i)
+		data[plane+p.y*g.width+p.x] = 1
+	}
+	head := s.body.front()
+	data[2*plane+head.y*g.width+head.x] = 1
+	for _, f := range g.food {
+		data[3*plane+f.y*g.width+f.x] = 1
+	}
+	return Observation{Shape: []int{4, g.height, g.width}, Data: data}
+}
+
+// turn rotates d a quarter turn clockwise per step; left is three steps.
+func turn(d Point, steps int) Point {
+	for i := 0; i < steps%4; i++ {
+		d = Point{-d.y, d.x}
+	}
+	return d
+}
+
+// encodeRays looks out from the head in eight directions, starting straight
+// ahead and going clockwise, and reports 1/distance to the nearest wall,
+// body segment and food along each ray (0 when there is none).
+func encodeRays(g *Game) Observation {
+	s := g.snakes[0]
+	head := s.body.front()
+	forward, right := s.direction, turn(s.direction, 1)
+	rays := []Point{
+		forward,
+		{forward.x + right.x, forward.y + right.y},
+		right,
+		{-forward.x + right.x, -forward.y + right.y},
+		{-forward.x, -forward.y},
+		{-forward.x - right.x, -forward.y - right.y},
+		{-right.x, -right.y},
+		{forward.x - right.x, forward.y - right.y},
+	}
+
+	data := make([]float32, 0, 3*len(rays))
+	for _, d := range rays {
+		var wall, seg, food float32
+		p := head
+		for dist := 1; ; dist++ {
+			p = Point{p.x + d.x, p.y + d.y}
+			if p.x <= 0 || p.y <= 0 || p.x >= g.width-1 || p.y >= g.height-1 {
+				wall = 1 / float32(dist)
+				break
+			}
+			if seg == 0 && g.grid[p.y*g.width+p.x] >= gridSnake {
+				seg = 1 / float32(dist)
+			}
+			if food == 0 && g.foodAt(p) >= 0 {
+				food = 1 / float32(dist)
+			}
+		}
+		data = append(data, wall, seg, food)
+	}
+	return Observation{Shape: []int{len(rays), 3}, Data: data}
+}
+
+// encodeFeatures is the classic compact encoding: danger straight ahead,
+// to the right and to the left; the heading one-hot as up, right, down,
+// left; and whether the nearest food is up, right, down or left of the
+// head.
+func encodeFeatures(g *Game) Observation {
+	s := g.snakes[0]
+	head := s.body.front()
+	data := make([]float32, 0, 11)
+	for _, steps := range []int{0, 1, 3} {
+		d := turn(s.direction, steps)
+		p := Point{head.x + d.x, head.y + d.y}
+		// The grid leaves the outer wall empty; the tail counts, as it
+		// does for update.
+		v := g.grid[p.y*g.width+p.x]
+		data = append(data, flag32(p.x <= 0 || p.y <= 0 || p.x >= g.width-1 || p.y >= g.height-1 || v == gridWall || v >= gridSnake))
+	}
+	for _, d := range dirs {
+		data = append(data, flag32(s.direction == d))
+	}
+
+	var food Point
+	nearest := -1
+	for _, f := range g.food {
+		if d := manhattan(head, f); nearest < 0 || d < nearest {
+			food, nearest = f, d
+		}
+	}
+	data = append(data,
+		flag32(nearest >= 0 && food.y < head.y),
+		flag32(nearest >= 0 && food.x > head.x),
+		flag32(nearest >= 0 && food.y > head.y),
+		flag32(nearest >= 0 && food.x < head.x),
+	)
+	return Observation{Shape: []int{11}, Data: data}
+}
+
+func flag32(b bool) float32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type envRequest struct {
+	Cmd      string  `json:"cmd"`
+	Seed     uint64  `json:"seed"`
+	Games    int     `json:"games,omitempty"`
+	Action   int     `json:"action"`
+	Actions  []int   `json:"actions,omitempty"`
+	Obs      string  `json:"obs,omitempty"`
+	Reward   *Reward `json:"reward,omitempty"`
+	Food     int     `json:"food,omitempty"`
+	MaxTicks int     `json:"max_ticks,omitempty"`
+}
+
+type envResponse struct {
+	Obs     *Observation `json:"obs,omitempty"`
+	Reward  float64      `json:"reward"`
+	Done    bool         `json:"done"`
+	Info    *Info        `json:"info,omitempty"`
+	Rewards []float64    `json:"rewards,omitempty"`
+	Dones   []bool       `json:"dones,omitempty"`
+	Infos   []Info       `json:"infos,omitempty"`
+	Actions int          `json:"actions,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// serveEnv gives every connection its own Env and answers one JSON request
+// per line: make (optional, reconfigures), reset and step. A reset with
+// games set starts a Batch of that many instead, and steps then take one
+// action per game.
+func serveEnv(conn net.Conn, obs string, reward Reward, foodCount, maxTicks int) {
+	defer conn.Close()
+	env, _ := NewEnv(obs, reward, foodCount, maxTicks)
+	var batch *Batch
+	dec := json.NewDecoder(bufio.NewReader(conn))
+	w := bufio.NewWriter(conn)
+	enc := json.NewEncoder(w)
+
+	for {
+		var req envRequest
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+
+		var resp envResponse
+		switch req.Cmd {
+		case "make":
+			o, r, f, m := obs, reward, foodCount, maxTicks
+			if req.Obs != "" {
+				o = req.Obs
+			}
+			if req.Reward != nil {
+				r = *req.Reward
+			}
+			if req.Food != 0 {
+				f = req.Food
+			}
+			if req.MaxTicks != 0 {
+				m = req.MaxTicks
+			}
+			next, err := NewEnv(o, r, f, m)
+			if err != nil {
+				resp.Error = err.Error()
+				break
+			}
+			env, batch = next, nil
+			resp.Actions = len(dirs)
+		case "reset":
+			if req.Games < 0 || req.Games > maxBatch {
+				resp.Error = fmt.Sprintf("games must be between 1 and %d", maxBatch)
+				break
+			}
+			var o Observation
+			if req.Games > 0 {
+				batch = env.Batch(req.Games, req.Seed)
+				o = batch.Observe()
+			} else {
+				batch = nil
+				o = env.Reset(req.Seed)
+			}
+			resp.Obs = &o
+			resp.Actions = len(dirs)
+		case "step":
+			if batch != nil {
+				if len(req.Actions) != len(batch.games) {
+					resp.Error = fmt.Sprintf("need %d actions, one per game, got %d", len(batch.games), len(req.Actions))
+					break
+				}
+				batch.Step(req.Actions)
+				o := batch.Observe()
+				resp.Obs, resp.Rewards, resp.Dones, resp.Infos = &o, batch.Rewards, batch.Dones, batch.Infos
+				break
+			}
+			if env.game == nil {
+				resp.Error = "step before reset"
+				break
+			}
+			if env.game.gameOver {
+				resp.Error = "episode is over, reset first"
+				break
+			}
+			o, r, done, info := env.Step(req.Action)
+			resp.Obs, resp.Reward, resp.Done, resp.Info = &o, r, done, &info
+		default:
+			resp.Error = fmt.Sprintf("unknown cmd %q", req.Cmd)
+		}
+
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func runEnv(args []string) {
+	fs := flag.NewFlagSet("env", flag.ExitOnError)
+	listen := fs.String("listen", "unix:/tmp/gsnake.sock", "`address` to serve on, unix:PATH or host:port")
+	obs := fs.String("obs", "features", "observation encoding: grid, rays or features")
+	foodCount := fs.Int("food", 1, "number of food items on the board")
+	maxTicks := fs.Int("max-ticks", 5000, "truncate episodes after this many steps (0 for no limit)")
+	reward := defaultReward
+	fs.Float64Var(&reward.Food, "reward-food", reward.Food, "reward for eating")
+	fs.Float64Var(&reward.Death, "reward-death", reward.Death, "reward for dying")
+	fs.Float64Var(&reward.Step, "reward-step", reward.Step, "reward added to every step")
+	fs.Float64Var(&reward.Win, "reward-win", reward.Win, "reward for filling the board")
+	fs.Parse(args)
+
+	if _, err := NewEnv(*obs, reward, *foodCount, *maxTicks); err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(2)
+	}
+
+	network, addr := "tcp", *listen
+	if path, ok := strings.CutPrefix(*listen, "unix:"); ok {
+		network, addr = "unix", path
+		os.Remove(path)
+	}
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+	log.Printf("gsnake env (%s observations) listening on %s", *obs, *listen)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "gsnake:", err)
+			os.Exit(1)
+		}
+		go serveEnv(conn, *obs, reward, *foodCount, *maxTicks)
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Palette indices for exported frames. Snake i is paletteSnake+i.
+const (
+	paletteBackground uint8 = iota
+	paletteWall
+	paletteFood
+	paletteDead
+	paletteSnake
+)
+
+func (t *Theme) palette() color.Palette {
+	p := color.Palette{t.Background, t.Wall, t.Food, t.Dead}
+	for _, c := range t.Snakes {
+		p = append(p, c)
+	}
+	return p
+}
+
+// paint stores the palette index of every cell of g in cells.
+func paint(g *Game, cells []uint8, snakes int) {
+	for c := range cells {
+		x, y := c%g.width, c/g.width
+		if x == 0 || y == 0 || x == g.width-1 || y == g.height-1 {
+			cells[c] = paletteWall
+		} else {
+			cells[c] = paletteBackground
+		}
+	}
+	for _, p := range g.walls {
+		cells[p.y*g.width+p.x] = paletteWall
+	}
+	for _, f := range g.food {
+		cells[f.y*g.width+f.x] = paletteFood
+	}
+	for i, s := range g.snakes {
+		for j := 0; j < s.body.len(); j++ {
+			p := s.body.at(j)
+			cells[p.y*g.width+p.x] = paletteSnake + uint8(i%snakes)
+		}
+		if s.dead {
+			head := s.body.front()
+			cells[head.y*g.width+head.x] = paletteDead
+		}
+	}
+}
+
+type cellChange struct {
+	at    int
+	color uint8
+}
+
+// exportFrame is what changed since the frame before, and how many extra
+// frame times to hold it for.
+type exportFrame struct {
+	changes []cellChange
+	hold    int
+}
+
+// timeline plays a replay and returns the first frame in full and every
+// later one as a list of changed cells. The board holds still for a moment
+// between rounds and at the end.
+func timeline(r *replay, t *Theme, fps int) (w, h int, first []uint8, frames []exportFrame, err error) {
+	var prev, cur []uint8
+	_, err = r.play(func(g *Game) {
+		if prev == nil {
+			w, h = g.width, g.height
+			prev, cur = make([]uint8, w*h), make([]uint8, w*h)
+			paint(g, prev, len(t.Snakes))
+			first = append([]uint8(nil), prev...)
+			frames = append(frames, exportFrame{})
+			return
+		}
+		paint(g, cur, len(t.Snakes))
+		var f exportFrame
+		for c := range cur {
+			if cur[c] != prev[c] {
+				f.changes = append(f.changes, cellChange{c, cur[c]})
+			}
+		}
+		if g.ticks == 0 {
+			frames[len(frames)-1].hold += fps
+		}
+		frames = append(frames, f)
+		prev, cur = cur, prev
+	})
+	if err == nil {
+		frames[len(frames)-1].hold += 2 * fps
+	}
+	return w, h, first, frames, err
+}
+
+// exportGIF draws the game as an animated GIF. After the first, each frame
+// only covers the cells that changed, drawn over what was already there,
+// so a long game doesn't mean a huge file.
+func exportGIF(out io.Writer, r *replay, t *Theme, size, fps int) error {
+	w, h, cells, frames, err := timeline(r, t, fps)
+	if err != nil {
+		return err
+	}
+	palette := t.palette()
+	delay := 100 / fps
+	if delay < 2 {
+		delay = 2
+	}
+
+	anim := &gif.GIF{Config: image.Config{ColorModel: palette, Width: w * size, Height: h * size}}
+	for i, f := range frames {
+		for _, c := range f.changes {
+			cells[c.at] = c.color
+		}
+		x0, y0, x1, y1 := 0, 0, w, h
+		if i > 0 {
+			x0, y0, x1, y1 = w, h, 0, 0
+			for _, c := range f.changes {
+				x, y := c.at%w, c.at/w
+				x0, y0 = min(x0, x), min(y0, y)
+				x1, y1 = max(x1, x+1), max(y1, y+1)
+			}
+			if len(f.changes) == 0 {
+				x0, y0, x1, y1 = 0, 0, 1, 1
+			}
+		}
+
+		img := image.NewPaletted(image.Rect(x0*size, y0*size, x1*size, y1*size), palette)
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				v := cells[y*w+x]
+				for py := y * size; py < (y+1)*size; py++ {
+					row := img.Pix[img.PixOffset(x*size, py):]
+					for px := 0; px < size; px++ {
+						row[px] = v
+					}
+				}
+			}
+		}
+		anim.Image = append(anim.Image, img)
+		anim.Delay = append(anim.Delay, delay*(1+f.hold))
+		anim.Disposal = append(anim.Disposal, gif.DisposalNone)
+	}
+	return gif.EncodeAll(out, anim)
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// exportSVG draws the game as an animated SVG. Cells that never change are
+// plain rectangles; every other cell gets a rectangle whose fill steps
+// through the colors it takes over the game, all on one looping clock.
+func exportSVG(out io.Writer, r *replay, t *Theme, size, fps int) error {
+	w, h, first, frames, err := timeline(r, t, fps)
+	if err != nil {
+		return err
+	}
+	palette := t.palette()
+
+	type key struct {
+		at    int
+		color uint8
+	}
+	history := make(map[int][]key)
+	clock := 0
+	for _, f := range frames {
+		for _, c := range f.changes {
+			if history[c.at] == nil {
+				history[c.at] = []key{{0, first[c.at]}}
+			}
+			history[c.at] = append(history[c.at], key{clock, c.color})
+		}
+		clock += 1 + f.hold
+	}
+	total := float64(clock) / float64(fps)
+
+	bw := bufio.NewWriter(out)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n",
+		w*size, h*size, w*size, h*size)
+	fmt.Fprintf(bw, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", hexColor(t.Background))
+	for c, v := range first {
+		if history[c] != nil || v == paletteBackground {
+			continue
+		}
+		fmt.Fprintf(bw, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n",
+			c%w*size, c/w*size, size, size, hexColor(palette[v].(color.RGBA)))
+	}
+	for c := 0; c < w*h; c++ {
+		keys := history[c]
+		if keys == nil {
+			continue
+		}
+		times := make([]string, len(keys))
+		values := make([]string, len(keys))
+		for i, k := range keys {
+			times[i] = fmt.Sprintf("%.6g", float64(k.at)/float64(clock))
+			values[i] = hexColor(palette[k.color].(color.RGBA))
+		}
+		fmt.Fprintf(bw, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s">`,
+			c%w*size, c/w*size, size, size, values[0])
+		fmt.Fprintf(bw, `<animate attributeName="fill" dur="%.3fs" repeatCount="indefinite" calcMode="discrete" keyTimes="%s" values="%s"/></rect>`+"\n",
+			total, strings.Join(times, ";"), strings.Join(values, ";"))
+	}
+	fmt.Fprintln(bw, "</svg>")
+	return bw.Flush()
+}
+
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "gif", "what to export to: gif or svg")
+	output := fs.String("out", "", "`file` to write, by default the replay's name with the format's extension")
+	size := fs.Int("cell", 12, "size of a board cell in pixels")
+	fps := fs.Int("fps", 10, "ticks shown per second")
+	themeName := fs.String("theme", mustLoadConfig().Theme, "color `theme`: "+themeNames())
+	path := parseReplayArgs(fs, args)
+	if path == "" || fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "usage: gsnake export REPLAY [--format gif|svg] [--out FILE] [flags]")
+		os.Exit(2)
+	}
+	if *format != "gif" && *format != "svg" {
+		fmt.Fprintln(os.Stderr, "gsnake: --format must be gif or svg")
+		os.Exit(2)
+	}
+	if *size < 1 || *fps < 1 {
+		fmt.Fprintln(os.Stderr, "gsnake: --cell and --fps must be positive")
+		os.Exit(2)
+	}
+	t, ok := themes[*themeName]
+	if !ok {
+		fmt.Fprintln(os.Stderr, "gsnake: unknown --theme", *themeName)
+		os.Exit(2)
+	}
+	if *output == "" {
+		*output = strings.TrimSuffix(path, filepath.Ext(path)) + "." + *format
+	}
+
+	r, err := loadReplay(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+	if *format == "gif" {
+		err = exportGIF(f, r, t, *size, *fps)
+	} else {
+		err = exportSVG(f, r, t, *size, *fps)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(*output)
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+}
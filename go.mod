@@ -1,3 +1,7 @@
 module gsnake
 
 go 1.25.1
+
+require golang.org/x/crypto v0.50.0
+
+require golang.org/x/sys v0.43.0 // indirect
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata with what the tests draw now")
+
+// fixture is a board to start a test from instead of the one a new round
+// sets up. Food eaten from it is replaced from seed like in any game.
+type fixture struct {
+	width, height int
+	snakes        []fixtureSnake
+	food          []Point
+	walls         []Point
+	seed          uint64
+}
+
+type fixtureSnake struct {
+	body []Point // head first
+	dir  Point
+}
+
+// game builds f the way loadGame builds a saved game: a fresh round, then
+// the grid redone from the fixture's snakes, walls and food.
+func (f fixture) game(t *testing.T) *Game {
+	t.Helper()
+	g := &Game{
+		width:     f.width,
+		height:    f.height,
+		players:   len(f.snakes),
+		foodCount: len(f.food),
+		seed:      f.seed,
+	}
+	g.init()
+
+	g.resetGrid()
+	take := func(p Point, v uint8) {
+		t.Helper()
+		if p.x <= 0 || p.y <= 0 || p.x >= g.width-1 || p.y >= g.height-1 {
+			t.Fatalf("fixture puts %v outside the board", p)
+		}
+		if g.grid[p.y*g.width+p.x] != gridEmpty {
+			t.Fatalf("fixture puts two things at %v", p)
+		}
+		g.take(p, v)
+	}
+	for i, s := range f.snakes {
+		for _, p := range s.body {
+			take(p, gridSnake+uint8(i))
+		}
+		g.snakes[i] = Snake{body: newRing(s.body, (g.width-2)*(g.height-2)), direction: s.dir}
+	}
+	g.walls = append(g.walls[:0], f.walls...)
+	for _, w := range f.walls {
+		take(w, gridWall)
+	}
+	g.food = append(g.food[:0], f.food...)
+	for _, p := range f.food {
+		take(p, gridFood)
+	}
+	return g
+}
+
+// stream is a Terminal made of a reader and a writer with no terminal
+// behind them, such as a test's buffers. It is as big as whoever made it
+// says.
+type stream struct {
+	io.Reader
+	io.Writer
+	cols, rows int
+}
+
+func (s *stream) Size() (cols, rows int) { return s.cols, s.rows }
+func (s *stream) Raw() func()            { return func() {} }
+
+// scriptKeys are the runes scripts are written in and the keystrokes they
+// stand for: arrows steer player 1 and wasd player 2, or player 1 too when
+// playing alone, like the keyboard does.
+var scriptKeys = map[rune]string{
+	'^': "\033[A",
+	'v': "\033[B",
+	'>': "\033[C",
+	'<': "\033[D",
+	'w': "w",
+	's': "s",
+	'a': "a",
+	'd': "d",
+}
+
+// play runs script on g a tick at a time and returns the frames drawn, the
+// one before the first tick included. Keys up to a '.' are typed during
+// the same tick and the '.' ends it, so "^." turns and moves once, ".."
+// moves twice without turning and "^<." is two presses before one move.
+// Keystrokes go through readInput and frames come out of render, both on
+// a stream standing in for the terminal. check, when not nil, is called
+// after every tick.
+func play(t *testing.T, g *Game, script string, check func(tick int)) []string {
+	t.Helper()
+	var typed bytes.Buffer
+	var frame strings.Builder
+	term := &stream{Reader: &typed, Writer: &frame}
+	g.render(term)
+	frames := []string{frame.String()}
+	for _, r := range script {
+		if r != '.' {
+			keys, ok := scriptKeys[r]
+			if !ok {
+				t.Fatalf("script has %q, which is no key", r)
+			}
+			typed.WriteString(keys)
+			continue
+		}
+		// readInput stops once everything typed has been read.
+		inputs := make(chan input, typed.Len())
+		readInput(term, inputs)
+		close(inputs)
+		for in := range inputs {
+			if in.player >= g.players {
+				in.player = 0
+			}
+			g.handleKey(in)
+		}
+		g.update()
+		frame.Reset()
+		g.render(term)
+		frames = append(frames, frame.String())
+		if check != nil {
+			check(len(frames) - 1)
+		}
+	}
+	return frames
+}
+
+var escapes = regexp.MustCompile("\033\\[[0-9;]*[A-Za-z]")
+
+// golden compares frames, without their colors, to testdata/name.golden,
+// or writes them there when the tests run with -update.
+func golden(t *testing.T, name string, frames []string) {
+	t.Helper()
+	var b strings.Builder
+	for i, f := range frames {
+		fmt.Fprintf(&b, "-- tick %d --\n%s", i, escapes.ReplaceAllString(f, ""))
+	}
+	got := b.String()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (go test -update writes it)", err)
+	}
+	want := string(data)
+	if got == want {
+		return
+	}
+	// Point at the first frame that differs rather than dumping them all.
+	gotFrames, wantFrames := strings.SplitAfter(got, "\n-- tick "), strings.SplitAfter(want, "\n-- tick ")
+	for i := range min(len(gotFrames), len(wantFrames)) {
+		if gotFrames[i] != wantFrames[i] {
+			t.Fatalf("%s: frame %d differs\ngot:\n%s\nwant:\n%s", path, i, gotFrames[i], wantFrames[i])
+		}
+	}
+	t.Fatalf("%s: drew %d frames, want %d", path, len(gotFrames), len(wantFrames))
+}
+
+func TestWallDeath(t *testing.T) {
+	g := fixture{
+		width: 12, height: 8,
+		snakes: []fixtureSnake{{body: []Point{{8, 3}, {7, 3}, {6, 3}}, dir: Point{1, 0}}},
+		food:   []Point{{2, 6}},
+	}.game(t)
+	frames := play(t, g, "...", func(tick int) {
+		if tick < 3 && g.gameOver {
+			t.Fatalf("game over after %d ticks, the wall is 3 away", tick)
+		}
+	})
+	if s := g.snakes[0]; !s.dead || s.cause != causeWall {
+		t.Errorf("snake dead %v, cause %q; want dead from %q", s.dead, s.cause, causeWall)
+	}
+	if h := g.snakes[0].body.front(); h != (Point{10, 3}) {
+		t.Errorf("head at %v, want it left at %v next to the wall", h, Point{10, 3})
+	}
+	golden(t, "wall_death", frames)
+}
+
+func TestLevelWallDeath(t *testing.T) {
+	g := fixture{
+		width: 12, height: 8,
+		snakes: []fixtureSnake{{body: []Point{{4, 5}, {3, 5}, {2, 5}}, dir: Point{1, 0}}},
+		walls:  []Point{{5, 2}, {6, 2}, {7, 2}},
+	}.game(t)
+	frames := play(t, g, "..^...", nil)
+	if s := g.snakes[0]; !s.dead || s.cause != causeWall {
+		t.Errorf("snake dead %v, cause %q; want dead from %q", s.dead, s.cause, causeWall)
+	}
+	golden(t, "level_wall_death", frames)
+}
+
+func TestSelfCollision(t *testing.T) {
+	// A hook whose head turns down into its own body.
+	g := fixture{
+		width: 12, height: 8,
+		snakes: []fixtureSnake{{
+			body: []Point{{5, 3}, {4, 3}, {3, 3}, {3, 4}, {4, 4}, {5, 4}, {6, 4}, {7, 4}},
+			dir:  Point{1, 0},
+		}},
+	}.game(t)
+	frames := play(t, g, "v.", nil)
+	if s := g.snakes[0]; !s.dead || s.cause != causeSelf {
+		t.Errorf("snake dead %v, cause %q; want dead from %q", s.dead, s.cause, causeSelf)
+	}
+	golden(t, "self_collision", frames)
+}
+
+func TestTailChase(t *testing.T) {
+	// The head turns into the cell the tail leaves this tick, which the
+	// board still counts as taken.
+	g := fixture{
+		width: 12, height: 8,
+		snakes: []fixtureSnake{{
+			body: []Point{{4, 3}, {5, 3}, {5, 4}, {4, 4}},
+			dir:  Point{-1, 0},
+		}},
+	}.game(t)
+	frames := play(t, g, "v.", nil)
+	if s := g.snakes[0]; !s.dead || s.cause != causeSelf {
+		t.Errorf("snake dead %v, cause %q; want dead from %q", s.dead, s.cause, causeSelf)
+	}
+	golden(t, "tail_chase", frames)
+}
+
+func TestReversal(t *testing.T) {
+	g := fixture{
+		width: 12, height: 8,
+		snakes: []fixtureSnake{{body: []Point{{4, 4}, {3, 4}, {2, 4}}, dir: Point{1, 0}}},
+	}.game(t)
+	// Straight back is ignored whichever way the snake heads; a quarter
+	// turn is taken. Two keys in one tick can't make a reversal either,
+	// but the second can undo the first.
+	steps := []struct {
+		script string
+		dir    Point
+	}{
+		{"<.", Point{1, 0}},
+		{"^.", Point{0, -1}},
+		{"v.", Point{0, -1}},
+		{"<.", Point{-1, 0}},
+		{">.", Point{-1, 0}},
+		{"v.", Point{0, 1}},
+		{"^.", Point{0, 1}},
+		{">^.", Point{1, 0}},
+		{"^<.", Point{0, -1}},
+		{">^.", Point{0, -1}},
+	}
+	var frames []string
+	for _, step := range steps {
+		f := play(t, g, step.script, nil)
+		if frames == nil {
+			frames = f[:1]
+		}
+		frames = append(frames, f[1:]...)
+		if got := g.snakes[0].direction; got != step.dir {
+			t.Fatalf("after %q heading %v, want %v", step.script, got, step.dir)
+		}
+		if g.gameOver {
+			t.Fatalf("after %q the snake died of %q", step.script, g.snakes[0].cause)
+		}
+	}
+	golden(t, "reversal", frames)
+}
+
+func TestFoodGrowth(t *testing.T) {
+	g := fixture{
+		width: 12, height: 8,
+		snakes: []fixtureSnake{{body: []Point{{4, 4}, {3, 4}, {2, 4}}, dir: Point{1, 0}}},
+		food:   []Point{{6, 4}},
+		seed:   1,
+	}.game(t)
+	frames := play(t, g, "...", func(tick int) {
+		s := g.snakes[0]
+		want := 3
+		if tick >= 2 {
+			want = 4
+		}
+		if s.body.len() != want || s.score != want-3 {
+			t.Fatalf("tick %d: length %d, score %d; want %d and %d", tick, s.body.len(), s.score, want, want-3)
+		}
+		if tick == 2 && s.body.back() != (Point{3, 4}) {
+			t.Fatalf("tail at %v after eating, want it left at %v", s.body.back(), Point{3, 4})
+		}
+		if len(g.food) != 1 {
+			t.Fatalf("tick %d: %d food on the board, want it put back", tick, len(g.food))
+		}
+	})
+	golden(t, "food_growth", frames)
+}
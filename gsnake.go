@@ -1,13 +1,16 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"math/rand"
+	"io"
+	"math/rand/v2"
+	"net"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
-	"unsafe"
 )
 
 type Point struct {
@@ -15,233 +18,952 @@ type Point struct {
 }
 
 type Snake struct {
-	body      []Point
+	body      ring
 	direction Point
+	score     int
+	dead      bool
+	cause     string
+}
+
+const (
+	causeWall  = "wall"
+	causeSelf  = "self"
+	causeBody  = "body"
+	causeHead  = "head"
+	causeLeft  = "disconnect"
+	causeCrash = "crash"
+)
+
+var causeText = map[string]string{
+	causeWall:  "hit a wall",
+	causeSelf:  "ran into itself",
+	causeBody:  "ran into the other snake",
+	causeHead:  "crashed head-on",
+	causeLeft:  "disconnected",
+	causeCrash: "bot crashed",
 }
 
 type Game struct {
 	width, height int
-	snake         Snake
-	food          Point
-	score         int
+	players       int
+	names         []string
+	autopilot     string
+	remote        bool
+	spectators    int
+	rounds        int
+	round         int
+	wins          []int
+	winner        int
+	snakes        []Snake
+	food          []Point
+	foodCount     int
+	level         string
+	walls         []Point
+	seed          uint64
+	pcg           *rand.PCG
+	rng           *rand.Rand
+	grid          []uint8
+	free          []int32
+	slot          []int32
+	heads         []Point
+	causes        []string
+	events        []Event
+	log           *replay
+	ticks         int
+	started       time.Time
+	elapsed       time.Duration
 	gameOver      bool
+	matchOver     bool
+	won           bool
 	quit          bool
+	paused        bool
+	resumed       bool
+	notice        string
+	menu          bool
+	toMenu        bool
+	daily         string
 }
 
-type termios struct {
-	Iflag  uint32
-	Oflag  uint32
-	Cflag  uint32
-	Lflag  uint32
-	Cc     [20]uint8
-	Ispeed uint32
-	Ospeed uint32
-}
+type Event int
 
-func tcgetattr(fd int) *termios {
-	var t termios
-	syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(0x5401), uintptr(unsafe.Pointer(&t)))
-	return &t
-}
+const (
+	EventFood Event = iota
+	EventDeath
+	EventVictory
+)
 
-func tcsetattr(fd int, t *termios) {
-	syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(0x5402), uintptr(unsafe.Pointer(t)))
+func (g *Game) init() {
+	if g.width == 0 || g.height == 0 {
+		g.width, g.height = defaultWidth, defaultHeight
+	}
+	// A restart draws the next match's seed from the last one, so every
+	// match can be replayed from its own seed.
+	if g.rng != nil {
+		g.seed = g.rng.Uint64()
+	}
+	g.pcg = rand.NewPCG(g.seed, g.seed)
+	g.rng = rand.New(g.pcg)
+	if g.players < 1 {
+		g.players = 1
+	}
+	if g.players == 1 || g.rounds < 1 {
+		g.rounds = 1
+	}
+	g.wins = make([]int, g.players)
+	g.round = 0
+	g.matchOver = false
+	g.quit = false
+	g.resumed = false
+	if g.log != nil {
+		*g.log = replay{
+			Version:   replayVersion,
+			Seed:      g.seed,
+			Width:     g.width,
+			Height:    g.height,
+			Players:   g.players,
+			Food:      g.foodCount,
+			Rounds:    g.rounds,
+			Autopilot: g.autopilot,
+			Level:     g.level,
+		}
+	}
+	g.newRound()
 }
 
-var originalTermios *termios
+func (g *Game) newRound() {
+	g.round++
+	x, y := g.width/4, g.height/2
+	start := []Point{{x, y}, {x - 1, y}, {x - 2, y}}
+	right := Point{1, 0}
+	bodies := [][]Point{start}
+	heading := []Point{right}
+	if g.players > 1 {
+		bodies = append(bodies, g.mirror(start))
+		heading = append(heading, Point{-right.x, -right.y})
+	}
+	if g.players > 2 {
+		var up []Point
+		for _, p := range start {
+			up = append(up, Point{p.x, p.y - g.height/4})
+		}
+		bodies = append(bodies, up)
+		heading = append(heading, right)
+	}
+	if g.players > 3 {
+		bodies = append(bodies, g.mirror(bodies[2]))
+		heading = append(heading, Point{-right.x, -right.y})
+	}
 
-func enableRawMode() {
-	originalTermios = tcgetattr(0)
-	raw := *originalTermios
-	raw.Lflag &^= 0x00000002 | 0x00000008
-	raw.Cc[6] = 1
-	raw.Cc[5] = 0
-	tcsetattr(0, &raw)
+	g.resetGrid()
+	g.snakes = make([]Snake, g.players)
+	for i := range g.snakes {
+		g.snakes[i] = Snake{body: newRing(bodies[i], (g.width-2)*(g.height-2)), direction: heading[i]}
+		for _, p := range bodies[i] {
+			g.take(p, gridSnake+uint8(i))
+		}
+	}
+	g.food = g.food[:0]
+	g.buildLevel()
+	for i := 0; i < g.foodCount; i++ {
+		if !g.spawnFood() {
+			break
+		}
+	}
+	g.ticks = 0
+	g.started = time.Now()
+	g.elapsed = 0
+	g.gameOver = false
+	g.won = false
+	g.winner = -1
+	g.paused = false
+	g.notice = ""
 }
 
-func disableRawMode() {
-	if originalTermios != nil {
-		tcsetattr(0, originalTermios)
+// mirror places a body point-symmetric to body, so both players start the
+// same distance from the walls heading towards each other.
+func (g *Game) mirror(body []Point) []Point {
+	m := make([]Point, 0, len(body))
+	for _, p := range body {
+		m = append(m, Point{g.width - 1 - p.x, g.height - 1 - p.y})
 	}
+	return m
 }
 
-func (g *Game) init() {
-	g.width = 40
-	g.height = 20
-	g.snake = Snake{
-		body: []Point{{10, 10}, {9, 10}, {8, 10}},
-		direction: Point{1, 0},
-	}
-	g.spawnFood()
-	g.score = 0
-	g.gameOver = false
-	g.quit = false
+func (g *Game) spawnFood() bool {
+	if len(g.free) == 0 {
+		return false
+	}
+	c := int(g.free[g.rng.IntN(len(g.free))])
+	f := Point{c % g.width, c / g.width}
+	g.take(f, gridFood)
+	g.food = append(g.food, f)
+	return true
 }
 
-func (g *Game) spawnFood() {
-	for {
-		g.food = Point{
-			x: rand.Intn(g.width-2) + 1,
-			y: rand.Intn(g.height-2) + 1,
-		}
-		valid := true
-		for _, segment := range g.snake.body {
-			if segment.x == g.food.x && segment.y == g.food.y {
-				valid = false
-				break
+func (g *Game) foodAt(p Point) int {
+	for i, f := range g.food {
+		if f == p {
+			return i
+		}
+	}
+	return -1
+}
+
+// update advances every live snake by one cell. Moves are simultaneous:
+// each new head is checked against the board as it stood at the start of
+// the tick, so a snake can't slip into a cell another tail is leaving.
+// The events slice is reused, so it is only good until the next update.
+func (g *Game) update() []Event {
+	if g.gameOver || g.quit {
+		return nil
+	}
+	g.ticks++
+	if g.log != nil {
+		g.log.EndRound, g.log.EndTick = g.round, g.ticks
+	}
+
+	if len(g.heads) != len(g.snakes) {
+		g.heads = make([]Point, len(g.snakes))
+		g.causes = make([]string, len(g.snakes))
+	}
+	heads, causes := g.heads, g.causes
+	for i, s := range g.snakes {
+		heads[i], causes[i] = Point{}, ""
+		if !s.dead {
+			head := s.body.front()
+			heads[i] = Point{
+				x: head.x + s.direction.x,
+				y: head.y + s.direction.y,
 			}
 		}
-		if valid {
-			break
+	}
+
+	for i, s := range g.snakes {
+		if !s.dead {
+			causes[i] = g.collision(i, heads)
+		}
+	}
+
+	events := g.events[:0]
+	eaten := 0
+	for i := range g.snakes {
+		s := &g.snakes[i]
+		if s.dead {
+			continue
+		}
+		if causes[i] != "" {
+			s.dead = true
+			s.cause = causes[i]
+			events = append(events, EventDeath)
+			continue
+		}
+
+		s.body.push(heads[i])
+
+		if g.grid[heads[i].y*g.width+heads[i].x] == gridFood {
+			f := g.foodAt(heads[i])
+			g.food = append(g.food[:f], g.food[f+1:]...)
+			s.score += 1
+			eaten++
+			events = append(events, EventFood)
+		} else {
+			g.release(s.body.pop())
+		}
+		g.take(heads[i], gridSnake+uint8(i))
+	}
+	g.events = events
+
+	switch {
+	case g.alive() < len(g.snakes):
+		g.endRound()
+	case g.players == 1 && g.snakes[0].body.len() == (g.width-2)*(g.height-2)-len(g.walls):
+		g.won = true
+		g.endRound()
+		events = append(events, EventVictory)
+	default:
+		for ; eaten > 0; eaten-- {
+			g.spawnFood()
 		}
 	}
+	return events
 }
 
-func (g *Game) update() {
-	if g.gameOver || g.quit {
-		return
+func (g *Game) collision(i int, heads []Point) string {
+	h := heads[i]
+	if h.x <= 0 || h.x >= g.width-1 || h.y <= 0 || h.y >= g.height-1 {
+		return causeWall
+	}
+	v := g.grid[h.y*g.width+h.x]
+	if v == gridWall {
+		return causeWall
 	}
 
-	head := g.snake.body[0]
-	newHead := Point{
-		x: head.x + g.snake.direction.x,
-		y: head.y + g.snake.direction.y,
+	for j, other := range g.snakes {
+		if j == i || other.dead {
+			continue
+		}
+		if heads[j] == h || (heads[j] == g.snakes[i].body.front() && h == other.body.front()) {
+			return causeHead
+		}
 	}
 
-	if newHead.x <= 0 || newHead.x >= g.width-1 || newHead.y <= 0 || newHead.y >= g.height-1 {
-		g.gameOver = true
-		return
+	if v >= gridSnake {
+		if int(v-gridSnake) == i {
+			return causeSelf
+		}
+		return causeBody
 	}
+	return ""
+}
 
-	for _, segment := range g.snake.body {
-		if newHead.x == segment.x && newHead.y == segment.y {
-			g.gameOver = true
-			return
+// kill takes a snake out of the round for reasons outside the board,
+// such as its player dropping off the network.
+func (g *Game) kill(i int, cause string) []Event {
+	s := &g.snakes[i]
+	if g.gameOver || s.dead {
+		return nil
+	}
+	s.dead = true
+	s.cause = cause
+	g.endRound()
+	return []Event{EventDeath}
+}
+
+func (g *Game) alive() int {
+	n := 0
+	for _, s := range g.snakes {
+		if !s.dead {
+			n++
 		}
 	}
+	return n
+}
+
+// endRound closes the current round and, once a player holds a majority of
+// the best-of-N rounds, the match. Tied matches go to sudden death.
+func (g *Game) endRound() {
+	g.gameOver = true
+	g.elapsed = time.Since(g.started)
+	if g.players == 1 {
+		g.matchOver = true
+		return
+	}
 
-	g.snake.body = append([]Point{newHead}, g.snake.body...)
+	g.winner = -1
+	if g.alive() == 1 {
+		for i, s := range g.snakes {
+			if !s.dead {
+				g.winner = i
+			}
+		}
+		g.wins[g.winner]++
+	}
 
-	if newHead.x == g.food.x && newHead.y == g.food.y {
-		g.score += 1
-		g.spawnFood()
-	} else {
-		g.snake.body = g.snake.body[:len(g.snake.body)-1]
+	leader, tied := 0, false
+	for i := 1; i < len(g.wins); i++ {
+		if g.wins[i] > g.wins[leader] {
+			leader, tied = i, false
+		} else if g.wins[i] == g.wins[leader] {
+			tied = true
+		}
+	}
+	if g.wins[leader] > g.rounds/2 || (g.round >= g.rounds && !tied) {
+		g.matchOver = true
 	}
 }
 
-func (g *Game) render() {
-	fmt.Print("\033[H\033[2J")
+const colorReset = "\033[0m"
+
+type cell struct {
+	r     rune
+	color string
+}
+
+// render draws the current frame to w in a single write, so whatever is on
+// the other end (a terminal, a recorder, a socket) sees whole frames.
+func (g *Game) render(w io.Writer) {
+	io.WriteString(w, g.frame())
+}
+
+// frame is everything render draws: a clear screen, the status line, the
+// board and whatever the game-over message calls for.
+func (g *Game) frame() string {
+	var out strings.Builder
+	out.WriteString("\033[H\033[2J")
 	
-	board := make([][]rune, g.height)
+	board := make([][]cell, g.height)
 	for i := range board {
-		board[i] = make([]rune, g.width)
+		board[i] = make([]cell, g.width)
 		for j := range board[i] {
 			if i == 0 || i == g.height-1 || j == 0 || j == g.width-1 {
-				board[i][j] = '█'
+				board[i][j] = cell{r: glyphs.Wall}
 			} else {
-				board[i][j] = ' '
+				board[i][j] = cell{r: ' '}
 			}
 		}
 	}
 
-	board[g.food.y][g.food.x] = '♦'
+	for _, p := range g.walls {
+		board[p.y][p.x] = cell{r: glyphs.Wall}
+	}
+	for _, f := range g.food {
+		board[f.y][f.x] = cell{r: glyphs.Food}
+	}
 
-	for _, segment := range g.snake.body {
-		board[segment.y][segment.x] = '■'
+	for i, s := range g.snakes {
+		color := theme.snakeColor(i)
+		for j := 0; j < s.body.len(); j++ {
+			segment := s.body.at(j)
+			board[segment.y][segment.x] = cell{glyphs.Body, color}
+		}
+		if s.dead {
+			head := s.body.front()
+			board[head.y][head.x] = cell{glyphs.Dead, color}
+		}
 	}
 
-	fmt.Printf("Score: %d | Arrow Keys to Move | Q to Quit\n", g.score)
+	fmt.Fprintln(&out, g.status())
 	for _, row := range board {
-		fmt.Println(string(row))
+		var b strings.Builder
+		color := ""
+		for _, c := range row {
+			if c.color != color {
+				if c.color == "" {
+					b.WriteString(colorReset)
+				} else {
+					b.WriteString(c.color)
+				}
+				color = c.color
+			}
+			b.WriteRune(c.r)
+		}
+		if color != "" {
+			b.WriteString(colorReset)
+		}
+		fmt.Fprintln(&out, b.String())
 	}
 	
+	quit, restart := keyName(keys.Quit), keyName(keys.Restart)
+	again := fmt.Sprintf("Press %s to quit or %s to restart\n", quit, restart)
+	next := fmt.Sprintf("Press %s for the next round or %s to quit\n", restart, quit)
+	if g.daily != "" {
+		again = fmt.Sprintf("Press %s to see your result\n", quit)
+	} else if g.menu {
+		again = fmt.Sprintf("Press %s to quit, %s to restart or %s for the menu\n", quit, restart, keyName(keys.Menu))
+		next = fmt.Sprintf("Press %s for the next round, %s for the menu or %s to quit\n", restart, keyName(keys.Menu), quit)
+	}
+	switch {
+	case g.paused && !g.gameOver && g.daily != "":
+		fmt.Fprintf(&out, "\nPAUSED - %s to resume, %s to end today's attempt here\n", keyName(keys.Pause), quit)
+	case g.paused && !g.gameOver:
+		fmt.Fprintf(&out, "\nPAUSED - %s to resume, %s to save, %s to save and quit\n", keyName(keys.Pause), keyName(keys.Save), quit)
+		if g.notice != "" {
+			fmt.Fprintln(&out, g.notice)
+		}
+	case g.players == 1 && g.won:
+		fmt.Fprintln(&out, "\nYOU WIN! The board is full. Final Score:", g.snakes[0].score)
+		fmt.Fprintf(&out, "Time: %s | Ticks: %d\n", g.elapsed.Round(time.Second), g.ticks)
+		out.WriteString(again)
+		out.WriteString(g.submitLine())
+	case g.players == 1 && g.gameOver:
+		fmt.Fprintln(&out, "\nGAME OVER! Final Score:", g.snakes[0].score)
+		out.WriteString(again)
+		out.WriteString(g.submitLine())
+	case g.gameOver:
+		fmt.Fprintln(&out)
+		for i, s := range g.snakes {
+			if s.dead {
+				fmt.Fprintf(&out, "%s %s\n", g.label(i), causeText[s.cause])
+			}
+		}
+		if g.winner >= 0 {
+			fmt.Fprintf(&out, "ROUND %d: %s wins!\n", g.round, g.label(g.winner))
+		} else {
+			fmt.Fprintf(&out, "ROUND %d: Draw!\n", g.round)
+		}
+		switch {
+		case g.matchOver && g.remote:
+			fmt.Fprintf(&out, "MATCH OVER! Wins %s\n", g.tally())
+			fmt.Fprintf(&out, "Back to the lobby shortly, %s to quit\n", quit)
+		case g.matchOver:
+			fmt.Fprintf(&out, "MATCH OVER! Wins %s\n", g.tally())
+			out.WriteString(again)
+		case g.remote:
+			fmt.Fprintf(&out, "Next round starting shortly, %s to quit\n", quit)
+		default:
+			out.WriteString(next)
+		}
+	}
+	return out.String()
+}
+
+// submitLine offers to send a finished one-player game to the leaderboard,
+// or says how sending it went.
+func (g *Game) submitLine() string {
+	switch {
+	case g.notice != "":
+		return g.notice + "\n"
+	case g.remote || g.autopilot != "" || g.resumed:
+		return ""
+	}
+	return fmt.Sprintf("Press %s to submit your score to the leaderboard\n", keyName(keys.Submit))
+}
+
+func (g *Game) status() string {
+	watching := ""
+	if g.spectators > 0 {
+		watching = fmt.Sprintf(" | %d watching", g.spectators)
+	}
+	if g.players == 1 && g.autopilot != "" {
+		return fmt.Sprintf("Score: %d%s | Autopilot (%s) | %s to Quit", g.snakes[0].score, watching, g.autopilot, keyName(keys.Quit))
+	}
+	if g.players == 1 {
+		return fmt.Sprintf("Score: %d%s | Arrow Keys to Move | %s to Quit", g.snakes[0].score, watching, keyName(keys.Quit))
+	}
+	scores := make([]string, len(g.snakes))
+	for i, s := range g.snakes {
+		scores[i] = fmt.Sprintf("%s%s: %d%s", theme.snakeColor(i), g.label(i), s.score, colorReset)
+	}
+	controls := fmt.Sprintf("P1 Arrows, P2 %s, %s to Quit", keys.steering(), keyName(keys.Quit))
+	if g.autopilot != "" {
+		controls = fmt.Sprintf("P1 Autopilot (%s), P2 %s, %s to Quit", g.autopilot, keys.steering(), keyName(keys.Quit))
+	}
+	if g.remote {
+		controls = fmt.Sprintf("Arrow Keys to Move, %s to Quit", keyName(keys.Quit))
+	}
+	return fmt.Sprintf("%s | Round %d (best of %d) | Wins %s%s | %s",
+		strings.Join(scores, "  "), g.round, g.rounds, g.tally(), watching, controls)
+}
+
+func (g *Game) label(i int) string {
+	if i < len(g.names) && g.names[i] != "" {
+		return g.names[i]
+	}
+	return fmt.Sprintf("P%d", i+1)
+}
+
+func (g *Game) tally() string {
+	wins := make([]string, len(g.wins))
+	for i, w := range g.wins {
+		wins[i] = fmt.Sprint(w)
+	}
+	return strings.Join(wins, "-")
+}
+
+// togglePause stops or restarts the clock on the round in progress, so time
+// spent paused doesn't count towards its duration.
+func (g *Game) togglePause() {
+	if g.gameOver {
+		return
+	}
+	if g.paused {
+		g.started = time.Now().Add(-g.elapsed)
+		g.notice = ""
+	} else {
+		g.elapsed = time.Since(g.started)
+	}
+	g.paused = !g.paused
+}
+
+// moved is the way s went on its last tick, from its neck to its head.
+// Turns are judged against it rather than direction, which an earlier key
+// in the same tick may already have changed.
+func (s *Snake) moved() Point {
+	if s.body.len() < 2 {
+		return s.direction
+	}
+	head, neck := s.body.at(0), s.body.at(1)
+	return Point{head.x - neck.x, head.y - neck.y}
+}
+
+// changeDirection turns a snake for its next move. Turning straight back
+// into its neck is ignored, even when it takes two keys in one tick, and
+// so is any turn once the round is over.
+func (g *Game) changeDirection(player, dx, dy int) {
 	if g.gameOver {
-		fmt.Println("\nGAME OVER! Final Score:", g.score)
-		fmt.Println("Press Q to quit or R to restart")
+		return
+	}
+	s := &g.snakes[player]
+	if moved := s.moved(); moved.x == -dx && moved.y == -dy {
+		return
+	}
+	if g.log != nil && s.direction != (Point{dx, dy}) {
+		g.log.Moves = append(g.log.Moves, replayMove{Round: g.round, Tick: g.ticks, Player: player, Dir: dirNames[Point{dx, dy}]})
 	}
+	s.direction = Point{dx, dy}
 }
 
-func (g *Game) changeDirection(dx, dy int) {
-	if g.snake.direction.x == -dx && g.snake.direction.y == -dy {
+type input struct {
+	player int
+	dir    Point
+	key    byte
+	// raw is the letter key as typed, before any binding is applied.
+	raw byte
+}
+
+func (g *Game) handleKey(in input) {
+	if in.dir != (Point{}) {
+		g.changeDirection(in.player, in.dir.x, in.dir.y)
 		return
 	}
-	g.snake.direction = Point{dx, dy}
+	switch in.key {
+	case 'q', 'Q':
+		g.quit = true
+	case 'p', 'P':
+		g.togglePause()
+	case 'm', 'M':
+		if g.gameOver && g.menu {
+			g.toMenu = true
+			g.quit = true
+		}
+	case 'r', 'R':
+		if g.daily != "" {
+			return
+		}
+		if g.matchOver {
+			g.init()
+		} else if g.gameOver {
+			g.newRound()
+		}
+	}
 }
 
-func (g *Game) handleInput() {
+// readInput turns raw keystrokes into inputs for the game loop until r
+// fails. Arrow keys steer player 1 and the steering letters (WASD unless
+// rebound) steer player 2; when playing alone the game loop hands those to
+// player 1 too. Other bound keys arrive as the letter they're bound in
+// place of, so the game only knows Q, P, K, R and M. Ctrl-C quits: it only
+// gets this far when no terminal turned it into a signal.
+func readInput(r io.Reader, inputs chan<- input) {
 	buffer := make([]byte, 1)
-	for !g.quit {
-		n, err := os.Stdin.Read(buffer)
-		if err != nil || n == 0 {
+	for {
+		n, err := r.Read(buffer)
+		if err != nil {
+			return
+		}
+		if n == 0 {
 			continue
 		}
 		
 		key := buffer[0]
 		
-		if key == 27 {
+		if key == 3 {
+			inputs <- input{key: 'q'}
+		} else if key == 27 {
 			seq := make([]byte, 2)
-			os.Stdin.Read(seq)
+			r.Read(seq)
 			if seq[0] == 91 {
 				switch seq[1] {
 				case 65:
-					g.changeDirection(0, -1)
+					inputs <- input{dir: Point{0, -1}}
 				case 66:
-					g.changeDirection(0, 1)
+					inputs <- input{dir: Point{0, 1}}
 				case 67:
-					g.changeDirection(1, 0)
+					inputs <- input{dir: Point{1, 0}}
 				case 68:
-					g.changeDirection(-1, 0)
+					inputs <- input{dir: Point{-1, 0}}
 				}
 			}
 		} else {
-			switch key {
-			case 'q', 'Q':
-				g.quit = true
-				return
-			case 'r', 'R':
-				if g.gameOver {
-					g.init()
-				}
+			in := input{raw: key}
+			switch keys.lower(key) {
+			case keys.Up:
+				in.player, in.dir = 1, Point{0, -1}
+			case keys.Down:
+				in.player, in.dir = 1, Point{0, 1}
+			case keys.Right:
+				in.player, in.dir = 1, Point{1, 0}
+			case keys.Left:
+				in.player, in.dir = 1, Point{-1, 0}
+			default:
+				in.key = keys.action(key)
 			}
+			inputs <- in
 		}
 	}
 }
 
 func main() {
-	rand.Seed(time.Now().UnixNano())
-	
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		case "join":
+			runJoin(os.Args[2:])
+			return
+		case "watch":
+			runWatch(os.Args[2:])
+			return
+		case "bot":
+			runBot(os.Args[2:])
+			return
+		case "tournament":
+			runTournamentCmd(os.Args[2:])
+			return
+		case "env":
+			runEnv(os.Args[2:])
+			return
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "stats":
+			runStats(os.Args[2:])
+			return
+		case "config":
+			runConfig(os.Args[2:])
+			return
+		case "daily":
+			runDaily(os.Args[2:])
+			return
+		case "leaderboard-server":
+			runLeaderboardServer(os.Args[2:])
+			return
+		case "leaderboard":
+			runLeaderboard(os.Args[2:])
+			return
+		case "verify":
+			runVerify(os.Args[2:])
+			return
+		case "web":
+			runWeb(os.Args[2:])
+			return
+		case "ssh-serve":
+			runSSHServe(os.Args[2:])
+			return
+		}
+	}
+
+	cfg := mustLoadConfig()
+	addConfigFlags(flag.CommandLine, cfg)
+	spectate := flag.String("spectate", "", "let spectators watch this game from `addr` with gsnake watch")
+	record := flag.String("record", "", "record the game to an asciinema cast `file`")
+	replayPath := flag.String("replay", "", "save a replay of the last match to `file`, for gsnake export")
+	resume := flag.Bool("resume", false, "carry on the game saved with K or by quitting mid-round")
+	flag.Parse()
+	if err := cfg.check(); err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(2)
+	}
+	cfg.use()
+	var resumed *Game
+	if *resume {
+		g, err := loadGame()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "gsnake:", err)
+			os.Exit(1)
+		}
+		resumed = g
+		cfg.Width, cfg.Height = g.width, g.height
+	}
+
+	var crowd *audience
+	if *spectate != "" {
+		ln, err := net.Listen("tcp", *spectate)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "gsnake:", err)
+			os.Exit(1)
+		}
+		crowd = newAudience(ln)
+	}
+
+	term := tty{}
+	out, rec, err := recordTo(term, *record, "gsnake", cfg.Width, cfg.Height)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+	if rec != nil {
+		defer rec.Close()
+	}
+
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	
-	enableRawMode()
-	defer disableRawMode()
-	
-	game := Game{}
-	game.init()
+	restore := term.Raw()
+	defer restore()
 	
-	go game.handleInput()
+	inputs := make(chan input, 16)
+	go readInput(term, inputs)
 	
 	go func() {
 		<-c
-		disableRawMode()
+		restore()
 		fmt.Println("\nGame terminated!")
 		os.Exit(0)
 	}()
 	
-	ticker := time.NewTicker(140 * time.Millisecond)
-	defer ticker.Stop()
+	board, err := openLeaderboard(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+	session := &localSession{inputs: inputs, out: out, crowd: crowd, board: board, name: cfg.Name}
+
+	// The menu comes up unless the command line already says what to play.
+	showMenu := resumed == nil && !cfg.fromFlags()
+	m := newMenu(cfg, out)
+	var game Game
+	for {
+		if showMenu {
+			m.resume = false
+			if !m.run(inputs) {
+				break
+			}
+			if m.resume {
+				if resumed, err = loadGame(); err != nil {
+					m.notice = "Couldn't load the saved game: " + err.Error()
+					continue
+				}
+			}
+		}
+		players := 1
+		if cfg.Mode == modeVersus {
+			players = 2
+		}
+		game = Game{
+			width:     cfg.Width,
+			height:    cfg.Height,
+			foodCount: cfg.Food,
+			players:   players,
+			rounds:    cfg.Rounds,
+			autopilot: cfg.Autopilot,
+			level:     cfg.Level,
+			seed:      uint64(time.Now().UnixNano()),
+			log:       &replay{},
+		}
+		if resumed != nil {
+			game = *resumed
+			resumed = nil
+		} else {
+			game.init()
+		}
+		game.menu = true
+		session.play(&game, cfg.Tick)
+		if !game.toMenu {
+			break
+		}
+		showMenu = true
+	}
 	
+	restore()
+	if *replayPath != "" && game.log != nil {
+		if err := game.log.save(*replayPath); err != nil {
+			fmt.Fprintln(os.Stderr, "gsnake:", err)
+		}
+	}
+	if game.snakes != nil && game.autopilot == "" && !game.gameOver {
+		if err := saveGame(&game); err != nil {
+			fmt.Fprintln(os.Stderr, "gsnake: couldn't save the game:", err)
+		} else {
+			fmt.Println("\nSaved, gsnake --resume picks it up again")
+		}
+	}
+	fmt.Println("\nThx for playing!")
+}
+
+// localSession is what games on this terminal are played with, besides
+// the game itself.
+type localSession struct {
+	inputs <-chan input
+	out    io.Writer
+	crowd  *audience
+	board  leaderboard
+	name   string
+	// hosted games are played from elsewhere, over SSH, and leave no
+	// saves, high scores or stats behind on this machine.
+	hosted bool
+}
+
+// play runs game, one tick every tick, until the player quits or heads back
+// to the menu.
+func (s *localSession) play(game *Game, tick time.Duration) {
+	var pilot Controller
+	if strategy, ok := strategies[game.autopilot]; ok {
+		pilot = strategy()
+	}
+	var watchEvents chan serverEvent
+	if s.crowd != nil {
+		watchEvents = s.crowd.events
+	}
+	mode := modeSolo
+	switch {
+	case pilot != nil:
+		mode = modeAutopilot
+	case game.daily != "":
+		mode = modeDaily
+	case game.players > 1:
+		mode = modeVersus
+	}
+
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
 	for !game.quit {
 		select {
-		case <-ticker.C:
-			if !game.gameOver {
-				game.update()
+		case in := <-s.inputs:
+			if in.player >= game.players {
+				in.player = 0
+			}
+			if pilot != nil && in.player == 0 && in.dir != (Point{}) {
+				break
+			}
+			if in.key == 'u' && game.gameOver && game.players == 1 && pilot == nil && !game.resumed && !strings.HasPrefix(game.notice, "Submitted") {
+				game.notice = "Submitting..."
+				game.render(s.out)
+				game.notice = submitScore(s.board, game, s.name)
+				game.render(s.out)
+				break
 			}
-			game.render()
+			if in.key == 'k' && pilot == nil && !game.gameOver && game.daily == "" {
+				if !game.paused {
+					game.togglePause()
+				}
+				game.notice = "Saved, gsnake --resume picks it up again"
+				if s.hosted {
+					game.notice = "Games played over SSH can't be saved"
+				} else if err := saveGame(game); err != nil {
+					game.notice = "Couldn't save: " + err.Error()
+				}
+				game.render(s.out)
+				break
+			}
+			game.handleKey(in)
+		case ev := <-watchEvents:
+			s.crowd.handle(ev, game)
+		case now := <-ticker.C:
+			if pilot != nil && game.gameOver && now.Sub(game.started) > game.elapsed+3*time.Second {
+				if game.matchOver {
+					game.init()
+				} else {
+					game.newRound()
+				}
+			}
+			if !game.gameOver && !game.paused {
+				if pilot != nil {
+					d := pilot.Next(game, 0)
+					game.changeDirection(0, d.x, d.y)
+				}
+				var prev *wireState
+				if s.crowd != nil {
+					if game.ticks == 0 {
+						s.crowd.broadcast(&message{Type: msgState, State: game.state()})
+					}
+					prev = game.state()
+				}
+				for _, ev := range game.update() {
+					if game.players == 1 && pilot == nil && !game.resumed && game.daily == "" && !s.hosted && (ev == EventDeath || ev == EventVictory) {
+						recordHighScore(game)
+					}
+				}
+				if game.gameOver && !s.hosted {
+					recordStats(game, mode)
+				}
+				if s.crowd != nil {
+					s.crowd.broadcast(&message{Type: msgTick, Tick: game.diff(prev)})
+				}
+			}
+			game.render(s.out)
 		}
 	}
-	
-	disableRawMode()
-	fmt.Println("\nThx for playing!")
 }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const maxHighScores = 10
+
+type scoreEntry struct {
+	Score    int           `json:"score"`
+	Ticks    int           `json:"ticks"`
+	Duration time.Duration `json:"duration"`
+	Date     time.Time     `json:"date"`
+}
+
+// highScores keeps victories apart from ordinary runs: a full board is
+// ranked by how fast it was cleared, everything else by score.
+type highScores struct {
+	Scores    []scoreEntry `json:"scores"`
+	Victories []scoreEntry `json:"victories"`
+}
+
+func dataDir() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "gsnake")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "."
+	}
+	return filepath.Join(home, ".local", "share", "gsnake")
+}
+
+func highScoresPath() string {
+	return filepath.Join(dataDir(), "highscores.json")
+}
+
+func loadHighScores() (*highScores, error) {
+	hs := &highScores{}
+	data, err := os.ReadFile(highScoresPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return hs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, hs); err != nil {
+		return nil, err
+	}
+	return hs, nil
+}
+
+func (hs *highScores) save() error {
+	data, err := json.MarshalIndent(hs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dataDir(), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(highScoresPath(), data, 0o644)
+}
+
+func (hs *highScores) add(e scoreEntry, won bool) {
+	if won {
+		hs.Victories = append(hs.Victories, e)
+		sort.SliceStable(hs.Victories, func(i, j int) bool {
+			return hs.Victories[i].Ticks < hs.Victories[j].Ticks
+		})
+		if len(hs.Victories) > maxHighScores {
+			hs.Victories = hs.Victories[:maxHighScores]
+		}
+		return
+	}
+	hs.Scores = append(hs.Scores, e)
+	sort.SliceStable(hs.Scores, func(i, j int) bool {
+		return hs.Scores[i].Score > hs.Scores[j].Score
+	})
+	if len(hs.Scores) > maxHighScores {
+		hs.Scores = hs.Scores[:maxHighScores]
+	}
+}
+
+func recordHighScore(g *Game) error {
+	hs, err := loadHighScores()
+	if err != nil {
+		return err
+	}
+	hs.add(scoreEntry{
+		Score:    g.snakes[0].score,
+		Ticks:    g.ticks,
+		Duration: g.elapsed,
+		Date:     time.Now(),
+	}, g.won)
+	return hs.save()
+}
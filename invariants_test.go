@@ -0,0 +1,123 @@
+package main
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+// The largest boards the invariant tests play on. Bigger ones find nothing
+// smaller ones don't and take longer to fill.
+const (
+	fuzzMaxWidth  = 40
+	fuzzMaxHeight = 24
+)
+
+// playInputs plays one tick for every byte of inputs on a board made from
+// the rest, checking the engine's invariants after every update. The top
+// two bits of a byte say what happens before the tick: 00 presses the
+// direction in the low two bits for the player in the two above them, 01
+// presses nothing, and 1x lets the astar autopilot steer that player, so
+// snakes live long enough to grow. A finished round is restarted with R,
+// so one run can play through several rounds and matches.
+func playInputs(t *testing.T, seed uint64, width, height, players int, level string, food int, inputs []byte) {
+	t.Helper()
+	g := &Game{
+		width:     width,
+		height:    height,
+		players:   players,
+		rounds:    3,
+		foodCount: food,
+		level:     level,
+		seed:      seed,
+	}
+	g.init()
+	checkInvariants(t, g, 0)
+	pilot := strategies["astar"]()
+	for i, b := range inputs {
+		if g.gameOver {
+			g.handleKey(input{key: 'r'})
+			checkInvariants(t, g, i)
+		}
+		player := int(b>>2) % players
+		switch {
+		case b&0x80 != 0:
+			if !g.snakes[player].dead {
+				d := pilot.Next(g, player)
+				g.changeDirection(player, d.x, d.y)
+			}
+		case b&0x40 == 0:
+			g.handleKey(input{player: player, dir: dirs[b&3]})
+		}
+		g.update()
+		checkInvariants(t, g, i+1)
+	}
+}
+
+// checkInvariants fails t if g is in a state no game should reach.
+func checkInvariants(t *testing.T, g *Game, step int) {
+	t.Helper()
+	taken := make(map[Point]bool)
+	for i, s := range g.snakes {
+		body := s.body.points()
+		if len(body) != 3+s.score {
+			t.Fatalf("step %d: snake %d is %d long with a score of %d", step, i, len(body), s.score)
+		}
+		seen := make(map[Point]bool, len(body))
+		for j, p := range body {
+			if j > 0 && manhattan(p, body[j-1]) != 1 {
+				t.Fatalf("step %d: snake %d has segment %d at %v and %d at %v", step, i, j-1, body[j-1], j, p)
+			}
+			if seen[p] && !s.dead {
+				t.Fatalf("step %d: live snake %d covers %v twice: %v", step, i, p, body)
+			}
+			seen[p] = true
+			taken[p] = true
+		}
+	}
+	walls := make(map[Point]bool, len(g.walls))
+	for _, w := range g.walls {
+		walls[w] = true
+	}
+	for _, f := range g.food {
+		switch {
+		case f.x <= 0 || f.y <= 0 || f.x >= g.width-1 || f.y >= g.height-1, walls[f]:
+			t.Fatalf("step %d: food at %v is on a wall", step, f)
+		case taken[f]:
+			t.Fatalf("step %d: food at %v is on a snake", step, f)
+		}
+	}
+}
+
+// FuzzEngine plays games from fuzzed seeds, boards and inputs. Run it with
+// go test -fuzz FuzzEngine; plain go test only plays the seeds below.
+func FuzzEngine(f *testing.F) {
+	f.Add(uint64(1), uint8(0), uint8(0), uint8(0), uint8(0), []byte("\x01\x01\x02\x02\x03\x03\x00\x00"))
+	f.Add(uint64(7), uint8(28), uint8(12), uint8(1), uint8(1), []byte("\x80\x84\x80\x84\x80\x84\x80\x84\x40\x44\x05\x07"))
+	f.Add(uint64(42), uint8(8), uint8(4), uint8(3), uint8(6), []byte("\x80\x84\x88\x8c\x80\x84\x88\x8c\x80\x84\x88\x8c"))
+	f.Fuzz(func(t *testing.T, seed uint64, width, height, players, options uint8, inputs []byte) {
+		w := minBoardWidth + int(width)%(fuzzMaxWidth-minBoardWidth+1)
+		h := minBoardHeight + int(height)%(fuzzMaxHeight-minBoardHeight+1)
+		level := levelOrder[int(options)%len(levelOrder)]
+		food := 1 + int(options)/len(levelOrder)%5
+		playInputs(t, seed, w, h, 1+int(players)%4, level, food, inputs)
+	})
+}
+
+// TestInvariants is the fuzz target's property test: many random games, the
+// same every run, with mostly the autopilot steering so snakes get long.
+func TestInvariants(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for range 200 {
+		inputs := make([]byte, 400)
+		for i := range inputs {
+			inputs[i] = byte(r.UintN(256))
+			if r.IntN(4) > 0 {
+				inputs[i] |= 0x80
+			}
+		}
+		playInputs(t, r.Uint64(),
+			minBoardWidth+r.IntN(fuzzMaxWidth-minBoardWidth+1),
+			minBoardHeight+r.IntN(fuzzMaxHeight-minBoardHeight+1),
+			1+r.IntN(4), levelOrder[r.IntN(len(levelOrder))], 1+r.IntN(5), inputs)
+	}
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Leaderboards are kept per mode. Only one-player games made by a person
+// count: an autopilot would top every board.
+var leaderboardModes = []string{modeSolo, modeDaily}
+
+// submission is a finished game sent to a leaderboard: what the player
+// claims and the replay that has to back it up.
+type submission struct {
+	Name   string  `json:"name"`
+	Mode   string  `json:"mode"`
+	Date   string  `json:"date,omitempty"`
+	Score  int     `json:"score"`
+	Length int     `json:"length"`
+	Ticks  int     `json:"ticks"`
+	Replay *replay `json:"replay"`
+}
+
+// boardEntry is an accepted submission as the leaderboard keeps it. ID is
+// the hash of the replay, so the same game can't be entered twice.
+type boardEntry struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Mode      string    `json:"mode"`
+	Date      string    `json:"date,omitempty"`
+	Score     int       `json:"score"`
+	Length    int       `json:"length"`
+	Ticks     int       `json:"ticks"`
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
+	Level     string    `json:"level,omitempty"`
+	Submitted time.Time `json:"submitted"`
+}
+
+// leaderboard is somewhere scores can be sent and read back: a
+// leaderboard-server over HTTP, or a file on this machine standing in for
+// one when no server is configured.
+type leaderboard interface {
+	submit(s *submission) (rank int, err error)
+	top(mode, date string, n int) ([]boardEntry, error)
+}
+
+// errRejected is a submission the leaderboard refused, as opposed to one
+// that couldn't reach it.
+var errRejected = errors.New("rejected")
+
+// check replays the submission and accepts it only if the game it
+// describes is the one claimed.
+func (s *submission) check() (boardEntry, error) {
+	var e boardEntry
+	reject := func(format string, args ...any) (boardEntry, error) {
+		return e, fmt.Errorf("%w: %s", errRejected, fmt.Sprintf(format, args...))
+	}
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" || len(s.Name) > 32 || strings.IndexFunc(s.Name, unicode.IsControl) >= 0 {
+		return reject("a name needs 1 to 32 printable characters")
+	}
+	r := s.Replay
+	switch {
+	case r == nil:
+		return reject("no replay")
+	case s.Mode != modeSolo && s.Mode != modeDaily:
+		return reject("no leaderboard for mode %q", s.Mode)
+	case r.Players != 1:
+		return reject("only one-player games go on the leaderboard")
+	case r.Autopilot != "":
+		return reject("autopilot games don't go on the leaderboard")
+	}
+	if s.Mode == modeDaily {
+		day, err := time.Parse(time.DateOnly, s.Date)
+		if err != nil {
+			return reject("daily scores need the date they were played")
+		}
+		ch := dailyFor(day)
+		if r.Seed != ch.Seed || r.Level != ch.Level || r.Food != ch.Food || r.Width != defaultWidth || r.Height != defaultHeight {
+			return reject("replay isn't %s's daily challenge", ch.Date)
+		}
+	} else {
+		s.Date = ""
+	}
+
+	if v := verifyReplay(r, &claim{Score: s.Score, Length: s.Length, Ticks: s.Ticks}); !v.ok() {
+		return reject("%s", strings.Join(v.Problems, "; "))
+	}
+
+	data, _ := json.Marshal(r)
+	sum := sha256.Sum256(data)
+	return boardEntry{
+		ID:        hex.EncodeToString(sum[:12]),
+		Name:      s.Name,
+		Mode:      s.Mode,
+		Date:      s.Date,
+		Score:     s.Score,
+		Length:    s.Length,
+		Ticks:     s.Ticks,
+		Width:     r.Width,
+		Height:    r.Height,
+		Level:     r.Level,
+		Submitted: time.Now().UTC(),
+	}, nil
+}
+
+// boardStore keeps accepted entries in memory and appends each to a JSON
+// lines file, which is read back in full on start.
+type boardStore struct {
+	mu      sync.Mutex
+	path    string
+	entries []boardEntry
+	ids     map[string]bool
+}
+
+func openBoardStore(path string) (*boardStore, error) {
+	b := &boardStore{path: path, ids: make(map[string]bool)}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e boardEntry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil && !b.ids[e.ID] {
+			b.entries = append(b.entries, e)
+			b.ids[e.ID] = true
+		}
+	}
+	return b, scanner.Err()
+}
+
+func (b *boardStore) submit(s *submission) (int, error) {
+	e, err := s.check()
+	if err != nil {
+		return 0, err
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return 0, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ids[e.ID] {
+		return 0, fmt.Errorf("%w: that game is already on the board", errRejected)
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0o755); err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(b.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return 0, err
+	}
+	if err := f.Close(); err != nil {
+		return 0, err
+	}
+	b.entries = append(b.entries, e)
+	b.ids[e.ID] = true
+
+	rank := 1
+	for _, other := range b.entries {
+		if other.Mode == e.Mode && other.Date == e.Date && other.beats(e) {
+			rank++
+		}
+	}
+	return rank, nil
+}
+
+// beats orders entries: higher score first, then fewer ticks, then
+// whoever got there first.
+func (e boardEntry) beats(o boardEntry) bool {
+	if e.Score != o.Score {
+		return e.Score > o.Score
+	}
+	if e.Ticks != o.Ticks {
+		return e.Ticks < o.Ticks
+	}
+	return e.Submitted.Before(o.Submitted)
+}
+
+func (b *boardStore) top(mode, date string, n int) ([]boardEntry, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []boardEntry
+	for _, e := range b.entries {
+		if e.Mode == mode && (date == "" || e.Date == date) {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].beats(out[j]) })
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out, nil
+}
+
+// boardClient talks to a leaderboard-server.
+type boardClient struct {
+	url  string
+	http *http.Client
+}
+
+type submitReply struct {
+	Rank  int    `json:"rank,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+func (c *boardClient) submit(s *submission) (int, error) {
+	body, err := json.Marshal(s)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.http.Post(c.url+"/submit", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	var reply submitReply
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return 0, fmt.Errorf("leaderboard answered %s", resp.Status)
+	}
+	if reply.Error != "" {
+		return 0, fmt.Errorf("%w: %s", errRejected, strings.TrimPrefix(reply.Error, errRejected.Error()+": "))
+	}
+	return reply.Rank, nil
+}
+
+func (c *boardClient) top(mode, date string, n int) ([]boardEntry, error) {
+	q := url.Values{"n": {strconv.Itoa(n)}}
+	if date != "" {
+		q.Set("date", date)
+	}
+	resp, err := c.http.Get(c.url + "/top/" + url.PathEscape(mode) + "?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("leaderboard answered %s", resp.Status)
+	}
+	var entries []boardEntry
+	return entries, json.NewDecoder(resp.Body).Decode(&entries)
+}
+
+func localBoardPath() string {
+	return filepath.Join(dataDir(), "leaderboard.jsonl")
+}
+
+// openLeaderboard returns the leaderboard the config points at, or the
+// local stand-in.
+func openLeaderboard(cfg *Config) (leaderboard, error) {
+	if cfg.Leaderboard == "" {
+		return openBoardStore(localBoardPath())
+	}
+	return &boardClient{url: strings.TrimSuffix(cfg.Leaderboard, "/"), http: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// submitScore sends the game just finished to lb and returns a line for the
+// game-over screen saying how it went. A resumed game is refused, since a
+// save can be loaded again and again until a run goes well.
+func submitScore(lb leaderboard, g *Game, name string) string {
+	if g.resumed {
+		return "Resumed games can't be submitted"
+	}
+	if name == "" {
+		name = "anonymous"
+	}
+	s := &submission{
+		Name:   name,
+		Mode:   modeSolo,
+		Score:  g.snakes[0].score,
+		Length: g.snakes[0].body.len(),
+		Ticks:  g.ticks,
+		Replay: g.log,
+	}
+	if g.daily != "" {
+		s.Mode, s.Date = modeDaily, g.daily
+	}
+	rank, err := lb.submit(s)
+	if err != nil {
+		return "Couldn't submit: " + err.Error()
+	}
+	return fmt.Sprintf("Submitted! #%d on the %s leaderboard", rank, s.Mode)
+}
+
+// maxSubmission caps a request body. An hour of furious steering is still
+// well under it.
+const maxSubmission = 8 << 20
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func leaderboardHandler(store *boardStore) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /submit", func(w http.ResponseWriter, r *http.Request) {
+		var s submission
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxSubmission)).Decode(&s); err != nil {
+			writeJSON(w, http.StatusBadRequest, submitReply{Error: "bad submission: " + err.Error()})
+			return
+		}
+		rank, err := store.submit(&s)
+		switch {
+		case errors.Is(err, errRejected):
+			log.Printf("rejected %q from %s: %v", s.Name, r.RemoteAddr, err)
+			writeJSON(w, http.StatusUnprocessableEntity, submitReply{Error: err.Error()})
+		case err != nil:
+			log.Printf("storing a score: %v", err)
+			writeJSON(w, http.StatusInternalServerError, submitReply{Error: "couldn't store the score"})
+		default:
+			log.Printf("%s scored %d (%s), #%d", s.Name, s.Score, s.Mode, rank)
+			writeJSON(w, http.StatusOK, submitReply{Rank: rank})
+		}
+	})
+	mux.HandleFunc("GET /top/{mode}", func(w http.ResponseWriter, r *http.Request) {
+		mode := r.PathValue("mode")
+		known := false
+		for _, m := range leaderboardModes {
+			known = known || m == mode
+		}
+		if !known {
+			writeJSON(w, http.StatusNotFound, submitReply{Error: "no leaderboard for mode " + strconv.Quote(mode)})
+			return
+		}
+		n, err := strconv.Atoi(r.URL.Query().Get("n"))
+		if err != nil || n < 1 || n > 100 {
+			n = 10
+		}
+		entries, _ := store.top(mode, r.URL.Query().Get("date"), n)
+		if entries == nil {
+			entries = []boardEntry{}
+		}
+		writeJSON(w, http.StatusOK, entries)
+	})
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"modes": leaderboardModes})
+	})
+	return mux
+}
+
+func runLeaderboardServer(args []string) {
+	fs := flag.NewFlagSet("leaderboard-server", flag.ExitOnError)
+	listen := fs.String("listen", ":8081", "`address` to serve the leaderboard on")
+	data := fs.String("data", "leaderboard.jsonl", "`file` the scores are kept in")
+	fs.Parse(args)
+
+	store, err := openBoardStore(*data)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+	log.Printf("leaderboard on %s, %d scores from %s", *listen, len(store.entries), *data)
+	if err := http.ListenAndServe(*listen, leaderboardHandler(store)); err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+}
+
+func runLeaderboard(args []string) {
+	fs := flag.NewFlagSet("leaderboard", flag.ExitOnError)
+	mode := fs.String("mode", modeSolo, "leaderboard to show: solo or daily")
+	date := fs.String("date", "", "for daily, the `day` to show, YYYY-MM-DD")
+	n := fs.Int("n", 10, "how many scores to show")
+	fs.Parse(args)
+
+	cfg := mustLoadConfig()
+	lb, err := openLeaderboard(cfg)
+	if err == nil {
+		var entries []boardEntry
+		if entries, err = lb.top(*mode, *date, *n); err == nil {
+			printBoard(entries)
+			return
+		}
+	}
+	fmt.Fprintln(os.Stderr, "gsnake:", err)
+	os.Exit(1)
+}
+
+func printBoard(entries []boardEntry) {
+	if len(entries) == 0 {
+		fmt.Println("no scores yet")
+		return
+	}
+	for i, e := range entries {
+		board := fmt.Sprintf("%dx%d", e.Width, e.Height)
+		if e.Level != "" && e.Level != "open" {
+			board += " " + e.Level
+		}
+		if e.Date != "" {
+			board = e.Date
+		}
+		fmt.Printf("%3d. %-20s %6d  %6d ticks  %s\n", i+1, e.Name, e.Score, e.Ticks, board)
+	}
+}
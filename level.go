@@ -0,0 +1,83 @@
+package main
+
+import "strings"
+
+// levels are the obstacle layouts a round can be played on, drawn to fit
+// whatever size the board is. Walls never go where a snake starts, so a
+// layout that would cover one on a small board just has a gap there.
+var levels = map[string]func(w, h int) []Point{
+	"open":    func(w, h int) []Point { return nil },
+	"pillars": pillars,
+	"tunnels": tunnels,
+	"box":     box,
+}
+
+// levelOrder is the order the menu cycles through levels in.
+var levelOrder = []string{"open", "pillars", "tunnels", "box"}
+
+func levelNames() string {
+	return strings.Join(levelOrder, ", ")
+}
+
+// pillars is four 2x2 blocks, one in each quarter of the board, clear of
+// the rows the snakes start on.
+func pillars(w, h int) []Point {
+	var walls []Point
+	for _, at := range []Point{{w / 3, h / 4}, {w - 1 - w/3 - 1, h / 4}, {w / 3, h - 1 - h/4 - 1}, {w - 1 - w/3 - 1, h - 1 - h/4 - 1}} {
+		walls = append(walls, at, Point{at.x + 1, at.y}, Point{at.x, at.y + 1}, Point{at.x + 1, at.y + 1})
+	}
+	return walls
+}
+
+// tunnels is two long bars across the board, a quarter of the way in from
+// the top and the bottom.
+func tunnels(w, h int) []Point {
+	var walls []Point
+	for x := w / 6; x <= w-1-w/6; x++ {
+		walls = append(walls, Point{x, h / 4}, Point{x, h - 1 - h/4})
+	}
+	return walls
+}
+
+// box is a second wall inside the first, with a two-cell door in the
+// middle of each side.
+func box(w, h int) []Point {
+	x0, y0, x1, y1 := w/8, h/6, w-1-w/8, h-1-h/6
+	door := func(v, lo, hi int) bool {
+		mid := (lo + hi) / 2
+		return v == mid || v == mid+1
+	}
+	var walls []Point
+	for x := x0; x <= x1; x++ {
+		if !door(x, x0, x1) {
+			walls = append(walls, Point{x, y0}, Point{x, y1})
+		}
+	}
+	for y := y0 + 1; y < y1; y++ {
+		if !door(y, y0, y1) {
+			walls = append(walls, Point{x0, y}, Point{x1, y})
+		}
+	}
+	return walls
+}
+
+// buildLevel puts the level's walls on the board. It runs after the snakes
+// are placed and before any food, and leaves out any wall that would sit
+// on a snake or on the outer wall.
+func (g *Game) buildLevel() {
+	g.walls = g.walls[:0]
+	build := levels[g.level]
+	if build == nil {
+		return
+	}
+	for _, p := range build(g.width, g.height) {
+		if p.x <= 0 || p.y <= 0 || p.x >= g.width-1 || p.y >= g.height-1 {
+			continue
+		}
+		if g.grid[p.y*g.width+p.x] != gridEmpty {
+			continue
+		}
+		g.take(p, gridWall)
+		g.walls = append(g.walls, p)
+	}
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// difficulties are the speeds the menu offers. Any other tick, set in the
+// config or with --tick, shows up as custom.
+var difficulties = []struct {
+	name string
+	tick time.Duration
+}{
+	{"easy", 200 * time.Millisecond},
+	{"normal", 140 * time.Millisecond},
+	{"hard", 90 * time.Millisecond},
+	{"insane", 50 * time.Millisecond},
+}
+
+// menuModes are the ways to play the menu cycles through.
+var menuModes = []struct {
+	name      string
+	mode      string
+	autopilot string
+}{
+	{"solo", modeSolo, ""},
+	{"versus", modeVersus, ""},
+	{"autopilot (bfs)", modeSolo, "bfs"},
+	{"autopilot (astar)", modeSolo, "astar"},
+	{"autopilot (hamilton)", modeSolo, "hamilton"},
+}
+
+var boardSizes = []Point{{30, 15}, {40, 20}, {60, 30}, {80, 40}}
+
+var glyphStyles = []struct {
+	name   string
+	glyphs glyphSet
+}{
+	{"blocks", glyphSet{Wall: '█', Food: '♦', Body: '■', Dead: '✖'}},
+	{"ascii", glyphSet{Wall: '#', Food: '*', Body: 'o', Dead: 'X'}},
+	{"round", glyphSet{Wall: '▓', Food: '●', Body: '○', Dead: '×'}},
+}
+
+// Screens the menu can show.
+const (
+	screenMain     = "main"
+	screenScores   = "scores"
+	screenSettings = "settings"
+)
+
+// menu is the title screen and the screens reached from it. Everything it
+// changes is written to the config file straight away.
+type menu struct {
+	cfg    *Config
+	out    io.Writer
+	screen string
+	cursor int
+	notice string
+	// binding is the key setting waiting for its new key, if any.
+	binding string
+	// resume is set when the player picked the saved game over a new one.
+	resume bool
+	quit   bool
+}
+
+// menuItem is one line of a screen that the cursor can land on. Items with
+// change are settings, stepped with left and right; items with pick are
+// actions, and pick reports whether the menu is done.
+type menuItem struct {
+	label  string
+	value  string
+	change func(step int)
+	pick   func() (done bool)
+}
+
+func newMenu(cfg *Config, out io.Writer) *menu {
+	return &menu{cfg: cfg, out: out, screen: screenMain}
+}
+
+// run shows the menu until the player picks Play or Quit, and reports
+// whether they want to play.
+func (m *menu) run(inputs <-chan input) bool {
+	for {
+		io.WriteString(m.out, m.frame())
+		if done, play := m.handle(<-inputs); done {
+			return play
+		}
+	}
+}
+
+// cycle steps through n choices from i, wrapping round at either end.
+func cycle(i, step, n int) int {
+	return ((i+step)%n + n) % n
+}
+
+func (m *menu) items() []menuItem {
+	switch m.screen {
+	case screenScores:
+		return []menuItem{{label: "Back", pick: m.back}}
+	case screenSettings:
+		return m.settingsItems()
+	}
+
+	c := m.cfg
+	var items []menuItem
+	items = append(items, menuItem{label: "Play", pick: func() bool { return true }})
+	if _, err := os.Stat(savePath()); err == nil {
+		items = append(items, menuItem{label: "Continue saved game", pick: func() bool {
+			m.resume = true
+			return true
+		}})
+	}
+
+	mode := 0
+	for i, mm := range menuModes {
+		if mm.mode == c.Mode && mm.autopilot == c.Autopilot {
+			mode = i
+		}
+	}
+	difficulty, speed := -1, "custom ("+c.Tick.String()+")"
+	for i, d := range difficulties {
+		if d.tick == c.Tick {
+			difficulty, speed = i, d.name
+		}
+	}
+	level := 0
+	for i, name := range levelOrder {
+		if name == c.Level {
+			level = i
+		}
+	}
+	return append(items,
+		menuItem{label: "Mode", value: menuModes[mode].name, change: func(step int) {
+			mm := menuModes[cycle(mode, step, len(menuModes))]
+			c.Mode, c.Autopilot = mm.mode, mm.autopilot
+			m.save("mode", "autopilot")
+		}},
+		menuItem{label: "Difficulty", value: speed, change: func(step int) {
+			if difficulty < 0 {
+				difficulty = 1 - step
+			}
+			c.Tick = difficulties[cycle(difficulty, step, len(difficulties))].tick
+			m.save("tick")
+		}},
+		menuItem{label: "Level", value: c.Level, change: func(step int) {
+			c.Level = levelOrder[cycle(level, step, len(levelOrder))]
+			m.save("level")
+		}},
+		menuItem{label: "High Scores", pick: func() bool {
+			m.screen, m.cursor = screenScores, 0
+			return false
+		}},
+		menuItem{label: "Settings", pick: func() bool {
+			m.screen, m.cursor = screenSettings, 0
+			return false
+		}},
+		menuItem{label: "Quit", pick: func() bool {
+			m.quit = true
+			return true
+		}},
+	)
+}
+
+func (m *menu) settingsItems() []menuItem {
+	c := m.cfg
+	size := -1
+	for i, s := range boardSizes {
+		if s.x == c.Width && s.y == c.Height {
+			size = i
+		}
+	}
+	style := -1
+	for i, s := range glyphStyles {
+		if s.glyphs == c.Glyphs {
+			style = i
+		}
+	}
+	styleName := string([]rune{c.Glyphs.Wall, c.Glyphs.Food, c.Glyphs.Body, c.Glyphs.Dead})
+	if style >= 0 {
+		styleName = glyphStyles[style].name + " " + styleName
+	}
+	current := 0
+	names := strings.Split(themeNames(), ", ")
+	for i, name := range names {
+		if name == c.Theme {
+			current = i
+		}
+	}
+
+	items := []menuItem{
+		{label: "Board size", value: fmt.Sprintf("%dx%d", c.Width, c.Height), change: func(step int) {
+			if size < 0 {
+				size = 1 - step
+			}
+			s := boardSizes[cycle(size, step, len(boardSizes))]
+			c.Width, c.Height = s.x, s.y
+			m.save("width", "height")
+		}},
+		{label: "Food", value: strconv.Itoa(c.Food), change: func(step int) {
+			m.set("food", strconv.Itoa(c.Food+step))
+		}},
+		{label: "Rounds (versus)", value: strconv.Itoa(c.Rounds), change: func(step int) {
+			m.set("rounds", strconv.Itoa(c.Rounds+2*step))
+		}},
+		{label: "Theme", value: c.Theme, change: func(step int) {
+			m.set("theme", names[cycle(current, step, len(names))])
+		}},
+		{label: "Glyphs", value: styleName, change: func(step int) {
+			if style < 0 {
+				style = -step
+			}
+			c.Glyphs = glyphStyles[cycle(style, step, len(glyphStyles))].glyphs
+			m.save("glyphs.wall", "glyphs.food", "glyphs.body", "glyphs.dead")
+		}},
+	}
+	for _, s := range settings {
+		if !strings.HasPrefix(s.key, "keys.") {
+			continue
+		}
+		key := s.key
+		value := keyName(s.get(c)[0])
+		if m.binding == key {
+			value = "press a key"
+		}
+		items = append(items, menuItem{label: "Key " + strings.TrimPrefix(key, "keys."), value: value, pick: func() bool {
+			m.binding = key
+			return false
+		}})
+	}
+	return append(items, menuItem{label: "Back", pick: m.back})
+}
+
+func (m *menu) back() bool {
+	m.screen, m.cursor = screenMain, 0
+	return false
+}
+
+// set changes one setting from the menu, quietly keeping the old value
+// when the new one is out of range.
+func (m *menu) set(key, value string) {
+	if findSetting(key).set(m.cfg, value) == nil {
+		m.save(key)
+	}
+}
+
+func (m *menu) save(keys ...string) {
+	m.cfg.use()
+	m.notice = ""
+	if err := saveConfig(m.cfg, keys...); err != nil {
+		m.notice = "Couldn't save settings: " + err.Error()
+	}
+}
+
+// bind gives key the letter b. Whatever had b before takes key's old
+// letter, so no two actions ever share one.
+func (m *menu) bind(key string, b byte) {
+	s := findSetting(key)
+	old := s.get(m.cfg)
+	if s.set(m.cfg, string(b)) != nil {
+		m.notice = "That key can't be bound"
+		return
+	}
+	changed := []string{key}
+	for _, other := range settings {
+		if other.key != key && strings.HasPrefix(other.key, "keys.") && other.get(m.cfg) == s.get(m.cfg) {
+			other.set(m.cfg, old)
+			changed = append(changed, other.key)
+		}
+	}
+	m.save(changed...)
+}
+
+// handle acts on one input. done is set once the player has picked Play or
+// Quit, and play tells which.
+func (m *menu) handle(in input) (done, play bool) {
+	if m.binding != "" {
+		if in.raw != 0 {
+			m.bind(m.binding, in.raw)
+			m.binding = ""
+		}
+		return false, false
+	}
+
+	items := m.items()
+	m.cursor = min(m.cursor, len(items)-1)
+	item := items[m.cursor]
+	switch {
+	case in.dir.y != 0:
+		m.cursor = cycle(m.cursor, in.dir.y, len(items))
+	case in.dir.x != 0 && item.change != nil:
+		item.change(in.dir.x)
+	case in.key == '\r' || in.key == '\n' || in.key == ' ':
+		if item.pick != nil {
+			done = item.pick()
+		} else if item.change != nil {
+			item.change(1)
+		}
+	case in.key == 'q':
+		if m.screen != screenMain {
+			m.back()
+			break
+		}
+		m.quit = true
+		done = true
+	}
+	return done, done && !m.quit
+}
+
+// frame draws the screen the way the game draws its board: a status line,
+// then the text inside a wall as wide as the board, so the menu previews
+// the board size, glyphs and colors picked.
+func (m *menu) frame() string {
+	var lines []string
+	var out strings.Builder
+	out.WriteString("\033[H\033[2J")
+	fmt.Fprintf(&out, "gsnake | Arrows or %s to move, Enter to pick, %s to quit\n", keys.steering(), keyName(keys.Quit))
+
+	items := m.items()
+	m.cursor = min(m.cursor, len(items)-1)
+	selected := -1
+	switch m.screen {
+	case screenMain:
+		lines = append(lines, "", "G S N A K E", "")
+	case screenScores:
+		lines = append(lines, "", "HIGH SCORES", "")
+		lines = append(lines, m.scoreLines()...)
+		lines = append(lines, "")
+	case screenSettings:
+		lines = append(lines, "", "SETTINGS", "")
+	}
+	width := 0
+	for _, it := range items {
+		width = max(width, utf8.RuneCountInString(it.label))
+	}
+	for i, it := range items {
+		line := it.label
+		if it.value != "" {
+			line = fmt.Sprintf("%-*s  < %s >", width, it.label, it.value)
+		}
+		if i == m.cursor {
+			selected = len(lines)
+			line = "> " + line
+		} else {
+			line = "  " + line
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "")
+
+	inner := m.cfg.Width - 2
+	for _, l := range lines {
+		inner = max(inner, utf8.RuneCountInString(l)+2)
+	}
+	wall := string(glyphs.Wall)
+	out.WriteString(strings.Repeat(wall, inner+2) + "\n")
+	for i, l := range lines {
+		pad := strings.Repeat(" ", inner-1-utf8.RuneCountInString(l))
+		if i == selected {
+			l = theme.snakeColor(0) + l + colorReset
+		}
+		fmt.Fprintf(&out, "%s %s%s%s\n", wall, l, pad, wall)
+	}
+	out.WriteString(strings.Repeat(wall, inner+2) + "\n")
+
+	switch {
+	case m.notice != "":
+		fmt.Fprintln(&out, m.notice)
+	case m.screen == screenSettings:
+		fmt.Fprintln(&out, "Saved to", configPath())
+	}
+	return out.String()
+}
+
+func (m *menu) scoreLines() []string {
+	hs, err := loadHighScores()
+	if err != nil {
+		return []string{"Couldn't read the high scores: " + err.Error()}
+	}
+	if len(hs.Scores) == 0 && len(hs.Victories) == 0 {
+		return []string{"No scores yet, go play"}
+	}
+	var lines []string
+	for i, e := range hs.Scores {
+		lines = append(lines, fmt.Sprintf("%2d. %5d  %s  %s", i+1, e.Score, e.Date.Local().Format(time.DateOnly), e.Duration.Round(time.Second)))
+	}
+	if len(hs.Victories) > 0 {
+		lines = append(lines, "", "Fastest full boards")
+		for i, e := range hs.Victories {
+			lines = append(lines, fmt.Sprintf("%2d. %5d ticks  %s  %s", i+1, e.Ticks, e.Date.Local().Format(time.DateOnly), e.Duration.Round(time.Second)))
+		}
+	}
+	return lines
+}
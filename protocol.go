@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+)
+
+// protocolVersion is bumped on any incompatible change to the messages
+// below. PROTOCOL.md describes the exchange in full.
+const protocolVersion = 1
+
+const (
+	msgHello   = "hello"
+	msgWelcome = "welcome"
+	msgError   = "error"
+	msgReady   = "ready"
+	msgDir     = "dir"
+	msgLobby   = "lobby"
+	msgState   = "state"
+	msgTick    = "tick"
+)
+
+const (
+	rolePlayer    = "player"
+	roleSpectator = "spectator"
+)
+
+type message struct {
+	Type    string     `json:"type"`
+	Version int        `json:"version,omitempty"`
+	Name    string     `json:"name,omitempty"`
+	Role    string     `json:"role,omitempty"`
+	ID      int        `json:"id,omitempty"`
+	Ready   bool       `json:"ready,omitempty"`
+	Dir     string     `json:"dir,omitempty"`
+	Error   string     `json:"error,omitempty"`
+	Lobby   *wireLobby `json:"lobby,omitempty"`
+	State   *wireState `json:"state,omitempty"`
+	Tick    *wireTick  `json:"tick,omitempty"`
+}
+
+type wirePoint [2]int
+
+type wireLobby struct {
+	Players    []wirePeer `json:"players"`
+	MinPlayers int        `json:"min_players"`
+	Playing    bool       `json:"playing"`
+	Spectators int        `json:"spectators"`
+}
+
+type wirePeer struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Ready bool   `json:"ready"`
+}
+
+type wireSnake struct {
+	ID    int         `json:"id,omitempty"`
+	Name  string      `json:"name"`
+	Body  []wirePoint `json:"body"`
+	Dir   string      `json:"dir"`
+	Score int         `json:"score"`
+	Dead  bool        `json:"dead,omitempty"`
+	Cause string      `json:"cause,omitempty"`
+}
+
+type wireState struct {
+	Width      int         `json:"width"`
+	Height     int         `json:"height"`
+	Round      int         `json:"round"`
+	Rounds     int         `json:"rounds"`
+	Tick       int         `json:"tick"`
+	Snakes     []wireSnake `json:"snakes"`
+	Food       []wirePoint `json:"food"`
+	Walls      []wirePoint `json:"walls,omitempty"`
+	Wins       []int       `json:"wins"`
+	Winner     int         `json:"winner"`
+	Over       bool        `json:"over"`
+	MatchOver  bool        `json:"match_over"`
+	Spectators int         `json:"spectators"`
+}
+
+// wireMove is one snake's change over a tick: a new head, whether the tail
+// stayed put, or how it died.
+type wireMove struct {
+	Head  *wirePoint `json:"head,omitempty"`
+	Grow  bool       `json:"grow,omitempty"`
+	Score int        `json:"score"`
+	Dead  bool       `json:"dead,omitempty"`
+	Cause string     `json:"cause,omitempty"`
+}
+
+type wireTick struct {
+	Tick       int         `json:"tick"`
+	Snakes     []wireMove  `json:"snakes"`
+	FoodAdd    []wirePoint `json:"food_add,omitempty"`
+	FoodDel    []wirePoint `json:"food_del,omitempty"`
+	Wins       []int       `json:"wins"`
+	Winner     int         `json:"winner"`
+	Over       bool        `json:"over"`
+	MatchOver  bool        `json:"match_over"`
+	Spectators int         `json:"spectators"`
+}
+
+var dirNames = map[Point]string{
+	{0, -1}: "up",
+	{0, 1}:  "down",
+	{-1, 0}: "left",
+	{1, 0}:  "right",
+}
+
+func parseDir(name string) (Point, bool) {
+	for d, n := range dirNames {
+		if n == name {
+			return d, true
+		}
+	}
+	return Point{}, false
+}
+
+func toWire(p Point) wirePoint {
+	return wirePoint{p.x, p.y}
+}
+
+func fromWire(p wirePoint) Point {
+	return Point{p[0], p[1]}
+}
+
+// wire carries protocol messages over some transport. lineConn is the TCP
+// one: a JSON object per line.
+type wire interface {
+	recv() (*message, error)
+	send(*message) error
+	close() error
+}
+
+type lineConn struct {
+	conn net.Conn
+	dec  *json.Decoder
+	w    *bufio.Writer
+	enc  *json.Encoder
+}
+
+func newLineConn(conn net.Conn) *lineConn {
+	w := bufio.NewWriter(conn)
+	return &lineConn{
+		conn: conn,
+		dec:  json.NewDecoder(bufio.NewReader(conn)),
+		w:    w,
+		enc:  json.NewEncoder(w),
+	}
+}
+
+func (c *lineConn) recv() (*message, error) {
+	var m message
+	if err := c.dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (c *lineConn) send(m *message) error {
+	if err := c.enc.Encode(m); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+func (c *lineConn) close() error {
+	return c.conn.Close()
+}
+
+// state snapshots the game for clients that need the whole board: round
+// starts and late joiners.
+func (g *Game) state() *wireState {
+	st := &wireState{
+		Width:      g.width,
+		Height:     g.height,
+		Round:      g.round,
+		Rounds:     g.rounds,
+		Tick:       g.ticks,
+		Wins:       append([]int(nil), g.wins...),
+		Winner:     g.winner,
+		Over:       g.gameOver,
+		MatchOver:  g.matchOver,
+		Spectators: g.spectators,
+	}
+	for i, s := range g.snakes {
+		ws := wireSnake{
+			Name:  g.label(i),
+			Dir:   dirNames[s.direction],
+			Score: s.score,
+			Dead:  s.dead,
+			Cause: s.cause,
+		}
+		for i := 0; i < s.body.len(); i++ {
+			ws.Body = append(ws.Body, toWire(s.body.at(i)))
+		}
+		st.Snakes = append(st.Snakes, ws)
+	}
+	for _, f := range g.food {
+		st.Food = append(st.Food, toWire(f))
+	}
+	for _, p := range g.walls {
+		st.Walls = append(st.Walls, toWire(p))
+	}
+	return st
+}
+
+func gameFromState(st *wireState) *Game {
+	g := &Game{
+		width:      st.Width,
+		height:     st.Height,
+		players:    len(st.Snakes),
+		remote:     true,
+		rounds:     st.Rounds,
+		round:      st.Round,
+		wins:       st.Wins,
+		winner:     st.Winner,
+		ticks:      st.Tick,
+		gameOver:   st.Over,
+		matchOver:  st.MatchOver,
+		spectators: st.Spectators,
+	}
+	for _, ws := range st.Snakes {
+		dir, _ := parseDir(ws.Dir)
+		body := make([]Point, 0, len(ws.Body))
+		for _, p := range ws.Body {
+			body = append(body, fromWire(p))
+		}
+		s := Snake{body: newRing(body, (g.width-2)*(g.height-2)), direction: dir, score: ws.Score, dead: ws.Dead, cause: ws.Cause}
+		g.snakes = append(g.snakes, s)
+		g.names = append(g.names, ws.Name)
+	}
+	for _, f := range st.Food {
+		g.food = append(g.food, fromWire(f))
+	}
+	for _, p := range st.Walls {
+		g.walls = append(g.walls, fromWire(p))
+	}
+	return g
+}
+
+// diff describes how the game moved on from prev, a snapshot taken before
+// the last update.
+func (g *Game) diff(prev *wireState) *wireTick {
+	t := &wireTick{
+		Tick:       g.ticks,
+		Wins:       append([]int(nil), g.wins...),
+		Winner:     g.winner,
+		Over:       g.gameOver,
+		MatchOver:  g.matchOver,
+		Spectators: g.spectators,
+	}
+	for i, s := range g.snakes {
+		before := prev.Snakes[i]
+		m := wireMove{Score: s.score}
+		switch {
+		case s.dead:
+			m.Dead = true
+			m.Cause = s.cause
+		case toWire(s.body.front()) != before.Body[0]:
+			head := toWire(s.body.front())
+			m.Head = &head
+			m.Grow = s.body.len() > len(before.Body)
+		}
+		t.Snakes = append(t.Snakes, m)
+	}
+
+	had := make(map[wirePoint]bool)
+	for _, f := range prev.Food {
+		had[f] = true
+	}
+	for _, f := range g.food {
+		if !had[toWire(f)] {
+			t.FoodAdd = append(t.FoodAdd, toWire(f))
+		}
+		delete(had, toWire(f))
+	}
+	for _, f := range prev.Food {
+		if had[f] {
+			t.FoodDel = append(t.FoodDel, f)
+		}
+	}
+	return t
+}
+
+func (g *Game) applyTick(t *wireTick) {
+	for i, m := range t.Snakes {
+		s := &g.snakes[i]
+		s.score = m.Score
+		switch {
+		case m.Dead:
+			s.dead = true
+			s.cause = m.Cause
+		case m.Head != nil:
+			s.body.push(fromWire(*m.Head))
+			if !m.Grow {
+				s.body.pop()
+			}
+		}
+	}
+	for _, f := range t.FoodDel {
+		if i := g.foodAt(fromWire(f)); i >= 0 {
+			g.food = append(g.food[:i], g.food[i+1:]...)
+		}
+	}
+	for _, f := range t.FoodAdd {
+		g.food = append(g.food, fromWire(f))
+	}
+	g.ticks = t.Tick
+	g.wins = t.Wins
+	g.winner = t.Winner
+	g.gameOver = t.Over
+	g.matchOver = t.MatchOver
+	g.spectators = t.Spectators
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// replayVersion is bumped whenever a replay file stops meaning the same
+// game to an older build.
+const replayVersion = 1
+
+// replay is enough to play a match again tick for tick: the settings it
+// started with, the seed its food came from and every change of direction.
+// The engine is deterministic given those, so nothing else is stored.
+type replay struct {
+	Version   int          `json:"version"`
+	Seed      uint64       `json:"seed"`
+	Width     int          `json:"width"`
+	Height    int          `json:"height"`
+	Players   int          `json:"players"`
+	Food      int          `json:"food"`
+	Rounds    int          `json:"rounds"`
+	Autopilot string       `json:"autopilot,omitempty"`
+	Level     string       `json:"level,omitempty"`
+	Moves     []replayMove `json:"moves"`
+	EndRound  int          `json:"end_round"`
+	EndTick   int          `json:"end_tick"`
+}
+
+// replayMove is a direction change made at the given tick of the given
+// round, before that tick's update.
+type replayMove struct {
+	Round  int    `json:"round"`
+	Tick   int    `json:"tick"`
+	Player int    `json:"player,omitempty"`
+	Dir    string `json:"dir"`
+}
+
+// parseReplayArgs parses fs's flags from args and returns the one replay
+// named among them, or "" when there's none. The replay can come before,
+// after or between the flags; anything more is left in fs.Args().
+func parseReplayArgs(fs *flag.FlagSet, args []string) string {
+	var path string
+	fs.Parse(args)
+	for fs.NArg() > 0 && path == "" {
+		path = fs.Arg(0)
+		fs.Parse(fs.Args()[1:])
+	}
+	return path
+}
+
+func loadReplay(path string) (*replay, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r replay
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if r.Version != replayVersion {
+		return nil, fmt.Errorf("%s: replay version %d, this build reads %d", path, r.Version, replayVersion)
+	}
+	return &r, nil
+}
+
+func (r *replay) save(path string) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// play runs the match again, calling frame with the starting board of every
+// round and after every tick, and returns the game as it ended.
+func (r *replay) play(frame func(g *Game)) (*Game, error) {
+	return r.run(frame, nil)
+}
+
+// run is play that also shows every move to look before it's made, when
+// look isn't nil.
+func (r *replay) run(frame func(g *Game), look func(g *Game, i int, m replayMove, d Point)) (*Game, error) {
+	if _, ok := levels[r.Level]; r.Level != "" && !ok {
+		return nil, fmt.Errorf("replay is on level %q, which this build doesn't have", r.Level)
+	}
+	if r.Players < 1 || r.Players > 4 || r.Food < 1 {
+		return nil, errors.New("replay has impossible settings")
+	}
+	if r.Width < minBoardWidth || r.Height < minBoardHeight || r.Width > maxBoardWidth || r.Height > maxBoardHeight {
+		return nil, fmt.Errorf("replay is for a %dx%d board, which can't be played", r.Width, r.Height)
+	}
+	g := &Game{
+		width:     r.Width,
+		height:    r.Height,
+		players:   r.Players,
+		foodCount: r.Food,
+		rounds:    r.Rounds,
+		autopilot: r.Autopilot,
+		level:     r.Level,
+		seed:      r.Seed,
+	}
+	g.init()
+	frame(g)
+
+	next := 0
+	for !(g.round == r.EndRound && g.ticks >= r.EndTick) {
+		if g.gameOver {
+			if g.matchOver || g.round >= r.EndRound {
+				break
+			}
+			g.newRound()
+			frame(g)
+			continue
+		}
+		for ; next < len(r.Moves) && r.Moves[next].Round == g.round && r.Moves[next].Tick == g.ticks; next++ {
+			m := r.Moves[next]
+			d, ok := parseDir(m.Dir)
+			if !ok || m.Player < 0 || m.Player >= g.players {
+				return nil, fmt.Errorf("bad move %d: player %d heading %q", next, m.Player, m.Dir)
+			}
+			if look != nil {
+				look(g, next, m, d)
+			}
+			g.changeDirection(m.Player, d.x, d.y)
+		}
+		g.update()
+		frame(g)
+	}
+	if next < len(r.Moves) {
+		return nil, fmt.Errorf("replay has %d moves that were never played", len(r.Moves)-next)
+	}
+	return g, nil
+}
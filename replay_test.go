@@ -0,0 +1,66 @@
+package main
+
+import (
+	"math/rand/v2"
+	"reflect"
+	"testing"
+)
+
+// record plays a whole match with a replay log, the autopilot steering
+// every snake with the odd random key thrown in, and returns the game as
+// it ended. Keys are pressed between rounds and after the match too, the
+// way a player still holding the arrows would.
+func record(t *testing.T, players int, seed uint64) *Game {
+	t.Helper()
+	g := &Game{
+		width:     16,
+		height:    10,
+		players:   players,
+		rounds:    3,
+		foodCount: 2,
+		seed:      seed,
+		log:       &replay{},
+	}
+	g.init()
+	pilot := strategies["astar"]()
+	r := rand.New(rand.NewPCG(seed, 3))
+	for !g.matchOver {
+		if g.ticks > 10000 {
+			t.Fatalf("round %d still going after %d ticks", g.round, g.ticks)
+		}
+		for i := range players {
+			d := dirs[r.IntN(len(dirs))]
+			if !g.snakes[i].dead && r.IntN(8) > 0 {
+				d = pilot.Next(g, i)
+			}
+			g.handleKey(input{player: i, dir: d})
+		}
+		if g.gameOver {
+			g.handleKey(input{key: 'r'})
+			continue
+		}
+		g.update()
+	}
+	for i := range players {
+		for _, d := range dirs {
+			g.handleKey(input{player: i, dir: d})
+		}
+	}
+	return g
+}
+
+func TestReplayPlaysBackTheGame(t *testing.T) {
+	for _, players := range []int{1, 2, 3} {
+		g := record(t, players, uint64(players))
+		got, err := g.log.play(func(*Game) {})
+		if err != nil {
+			t.Fatalf("%d players: %v", players, err)
+		}
+		if want := g.state(); !reflect.DeepEqual(got.state(), want) {
+			t.Errorf("%d players: the replay ends at\n%+v\nwant\n%+v", players, got.state(), want)
+		}
+		if v := verifyReplay(g.log, nil); !v.ok() {
+			t.Errorf("%d players: an honest replay fails verification: %v", players, v.Problems)
+		}
+	}
+}
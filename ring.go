@@ -0,0 +1,118 @@
+package main
+
+// ring holds a snake's segments head first in a circular buffer, so a move
+// is one push at the head and one pop at the tail whatever the length.
+type ring struct {
+	buf  []Point
+	head int
+	n    int
+}
+
+// newRing builds a ring from points, head first, with room for capacity
+// segments before it has to grow.
+func newRing(points []Point, capacity int) ring {
+	if capacity < len(points) {
+		capacity = len(points)
+	}
+	r := ring{buf: make([]Point, capacity)}
+	for i := len(points) - 1; i >= 0; i-- {
+		r.push(points[i])
+	}
+	return r
+}
+
+func (r *ring) len() int { return r.n }
+
+// at returns the i'th segment counting from the head.
+func (r *ring) at(i int) Point { return r.buf[(r.head+i)%len(r.buf)] }
+
+func (r *ring) front() Point { return r.at(0) }
+func (r *ring) back() Point  { return r.at(r.n - 1) }
+
+// push adds a new head.
+func (r *ring) push(p Point) {
+	if r.n == len(r.buf) {
+		r.grow()
+	}
+	r.head = (r.head + len(r.buf) - 1) % len(r.buf)
+	r.buf[r.head] = p
+	r.n++
+}
+
+// pop drops the tail and returns it.
+func (r *ring) pop() Point {
+	p := r.back()
+	r.n--
+	return p
+}
+
+func (r *ring) grow() {
+	buf := make([]Point, 2*len(r.buf)+1)
+	for i := 0; i < r.n; i++ {
+		buf[i] = r.at(i)
+	}
+	r.buf, r.head = buf, 0
+}
+
+// points copies the segments out, head first, for code that wants a plain
+// slice to work on.
+func (r *ring) points() []Point {
+	out := make([]Point, r.n)
+	for i := range out {
+		out[i] = r.at(i)
+	}
+	return out
+}
+
+// The occupancy grid holds what is in every cell of the board: nothing,
+// food, a level's wall, or snake i as gridSnake+i. Empty cells inside the walls are also
+// kept in an unordered list, with each cell's position in it, so food can
+// be dropped on a random one without scanning the board.
+const (
+	gridEmpty uint8 = iota
+	gridFood
+	gridWall
+	gridSnake
+)
+
+func (g *Game) resetGrid() {
+	size := g.width * g.height
+	if len(g.grid) != size {
+		g.grid = make([]uint8, size)
+		g.slot = make([]int32, size)
+		g.free = make([]int32, 0, size)
+	}
+	g.free = g.free[:0]
+	for c := range g.grid {
+		g.grid[c] = gridEmpty
+		g.slot[c] = -1
+		x, y := c%g.width, c/g.width
+		if x > 0 && y > 0 && x < g.width-1 && y < g.height-1 {
+			g.slot[c] = int32(len(g.free))
+			g.free = append(g.free, int32(c))
+		}
+	}
+}
+
+// take marks p as holding v and strikes it off the free list.
+func (g *Game) take(p Point, v uint8) {
+	c := p.y*g.width + p.x
+	g.grid[c] = v
+	if i := g.slot[c]; i >= 0 {
+		last := g.free[len(g.free)-1]
+		g.free[i] = last
+		g.slot[last] = i
+		g.free = g.free[:len(g.free)-1]
+		g.slot[c] = -1
+	}
+}
+
+// release empties p and puts it back on the free list.
+func (g *Game) release(p Point) {
+	c := p.y*g.width + p.x
+	g.grid[c] = gridEmpty
+	if g.slot[c] < 0 {
+		g.slot[c] = int32(len(g.free))
+		g.free = append(g.free, int32(c))
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// saveVersion is bumped whenever the save file changes shape; older saves
+// are refused rather than half-loaded.
+const saveVersion = 1
+
+type savedSnake struct {
+	Body  []wirePoint `json:"body"`
+	Dir   string      `json:"dir"`
+	Score int         `json:"score"`
+	Dead  bool        `json:"dead,omitempty"`
+	Cause string      `json:"cause,omitempty"`
+}
+
+// saveFile is a local game frozen mid-match: everything the engine needs to
+// carry on exactly where it stopped, plus the replay so far. That includes
+// the food generator's state and the order of the free-cell list it picks
+// from, since the same draw from a differently ordered list lands the food
+// somewhere else.
+type saveFile struct {
+	Version   int           `json:"version"`
+	Saved     time.Time     `json:"saved"`
+	Width     int           `json:"width"`
+	Height    int           `json:"height"`
+	Players   int           `json:"players"`
+	Food      int           `json:"food"`
+	Rounds    int           `json:"rounds"`
+	Round     int           `json:"round"`
+	Wins      []int         `json:"wins"`
+	Autopilot string        `json:"autopilot,omitempty"`
+	Level     string        `json:"level,omitempty"`
+	Walls     []wirePoint   `json:"walls,omitempty"`
+	Seed      uint64        `json:"seed"`
+	RNG       []byte        `json:"rng"`
+	Ticks     int           `json:"ticks"`
+	Elapsed   time.Duration `json:"elapsed"`
+	Snakes    []savedSnake  `json:"snakes"`
+	FoodAt    []wirePoint   `json:"food_at"`
+	Free      []int32       `json:"free"`
+	Replay    *replay       `json:"replay,omitempty"`
+}
+
+func savePath() string {
+	return filepath.Join(dataDir(), "save.json")
+}
+
+// saveGame writes g to the save file. Only a round in progress is worth
+// saving; one that's over would resume straight into a game-over screen.
+func saveGame(g *Game) error {
+	if g.gameOver {
+		return errors.New("nothing to save, the round is over")
+	}
+	rng, err := g.pcg.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	elapsed := g.elapsed
+	if !g.paused {
+		elapsed = time.Since(g.started)
+	}
+	sf := saveFile{
+		Version:   saveVersion,
+		Saved:     time.Now(),
+		Width:     g.width,
+		Height:    g.height,
+		Players:   g.players,
+		Food:      g.foodCount,
+		Rounds:    g.rounds,
+		Round:     g.round,
+		Wins:      g.wins,
+		Autopilot: g.autopilot,
+		Level:     g.level,
+		Seed:      g.seed,
+		RNG:       rng,
+		Ticks:     g.ticks,
+		Elapsed:   elapsed,
+		Free:      g.free,
+		Replay:    g.log,
+	}
+	for _, s := range g.snakes {
+		ss := savedSnake{Dir: dirNames[s.direction], Score: s.score, Dead: s.dead, Cause: s.cause}
+		for i := 0; i < s.body.len(); i++ {
+			ss.Body = append(ss.Body, toWire(s.body.at(i)))
+		}
+		sf.Snakes = append(sf.Snakes, ss)
+	}
+	for _, f := range g.food {
+		sf.FoodAt = append(sf.FoodAt, toWire(f))
+	}
+	for _, p := range g.walls {
+		sf.Walls = append(sf.Walls, toWire(p))
+	}
+
+	data, err := json.Marshal(sf)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dataDir(), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(savePath(), data, 0o644)
+}
+
+// loadGame restores the saved game, paused and marked as resumed, and
+// removes the save so the same position can't be loaded twice.
+func loadGame() (*Game, error) {
+	data, err := os.ReadFile(savePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errors.New("no saved game")
+	}
+	if err != nil {
+		return nil, err
+	}
+	var sf saveFile
+	if err := json.Unmarshal(data, &sf); err != nil {
+		return nil, fmt.Errorf("%s: %w", savePath(), err)
+	}
+	if sf.Version != saveVersion {
+		return nil, fmt.Errorf("%s: save version %d, this build reads %d", savePath(), sf.Version, saveVersion)
+	}
+	if sf.Players < 1 || sf.Players > 4 || len(sf.Snakes) != sf.Players || len(sf.Wins) != sf.Players || sf.Width < 3 || sf.Height < 3 {
+		return nil, fmt.Errorf("%s: damaged save", savePath())
+	}
+
+	g := &Game{
+		width:     sf.Width,
+		height:    sf.Height,
+		players:   sf.Players,
+		foodCount: sf.Food,
+		rounds:    sf.Rounds,
+		round:     sf.Round,
+		wins:      sf.Wins,
+		winner:    -1,
+		autopilot: sf.Autopilot,
+		level:     sf.Level,
+		seed:      sf.Seed,
+		pcg:       &rand.PCG{},
+		ticks:     sf.Ticks,
+		elapsed:   sf.Elapsed,
+		log:       sf.Replay,
+		paused:    true,
+		resumed:   true,
+	}
+	if err := g.pcg.UnmarshalBinary(sf.RNG); err != nil {
+		return nil, fmt.Errorf("%s: %w", savePath(), err)
+	}
+	g.rng = rand.New(g.pcg)
+
+	inside := func(p Point) bool { return p.x > 0 && p.y > 0 && p.x < g.width-1 && p.y < g.height-1 }
+	g.resetGrid()
+	for i, ss := range sf.Snakes {
+		dir, ok := parseDir(ss.Dir)
+		if !ok || len(ss.Body) == 0 {
+			return nil, fmt.Errorf("%s: damaged save", savePath())
+		}
+		body := make([]Point, len(ss.Body))
+		for j, p := range ss.Body {
+			body[j] = fromWire(p)
+			if !inside(body[j]) {
+				return nil, fmt.Errorf("%s: damaged save", savePath())
+			}
+			g.take(body[j], gridSnake+uint8(i))
+		}
+		g.snakes = append(g.snakes, Snake{
+			body:      newRing(body, (g.width-2)*(g.height-2)),
+			direction: dir,
+			score:     ss.Score,
+			dead:      ss.Dead,
+			cause:     ss.Cause,
+		})
+	}
+	for _, p := range sf.Walls {
+		w := fromWire(p)
+		if !inside(w) {
+			return nil, fmt.Errorf("%s: damaged save", savePath())
+		}
+		g.take(w, gridWall)
+		g.walls = append(g.walls, w)
+	}
+	for _, p := range sf.FoodAt {
+		f := fromWire(p)
+		if !inside(f) {
+			return nil, fmt.Errorf("%s: damaged save", savePath())
+		}
+		g.take(f, gridFood)
+		g.food = append(g.food, f)
+	}
+	if len(sf.Free) != len(g.free) {
+		return nil, fmt.Errorf("%s: damaged save", savePath())
+	}
+	for i, c := range sf.Free {
+		if c < 0 || int(c) >= len(g.grid) || g.slot[c] < 0 {
+			return nil, fmt.Errorf("%s: damaged save", savePath())
+		}
+		g.slot[c] = int32(i)
+	}
+	g.free = sf.Free
+	g.started = time.Now().Add(-g.elapsed)
+
+	if err := os.Remove(savePath()); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestSaveAndResume(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	g := &Game{width: 20, height: 12, players: 2, rounds: 3, foodCount: 3, level: "pillars", seed: 9, log: &replay{}}
+	g.init()
+	pilot := strategies["astar"]()
+	steer := func(g *Game, i int, d Point) {
+		if !g.snakes[i].dead {
+			g.changeDirection(i, d.x, d.y)
+		}
+	}
+	for range 30 {
+		for i := range g.players {
+			steer(g, i, pilot.Next(g, i))
+		}
+		g.update()
+	}
+	if g.gameOver {
+		t.Fatalf("round over after %d ticks, want one still going to save", g.ticks)
+	}
+
+	if err := saveGame(g); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadGame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.paused || !loaded.resumed {
+		t.Errorf("loaded game paused %v, resumed %v; want both", loaded.paused, loaded.resumed)
+	}
+	if _, err := loadGame(); err == nil {
+		t.Error("the save loaded twice")
+	}
+	loaded.togglePause()
+
+	// Both copies take the same turns from here, through the end of this
+	// round and into the ones after, so every food draw and new round has
+	// to come out of the generator and free list the same way.
+	for tick := range 300 {
+		if g.matchOver {
+			break
+		}
+		if g.gameOver {
+			g.handleKey(input{key: 'r'})
+			loaded.handleKey(input{key: 'r'})
+		}
+		for i := range g.players {
+			d := pilot.Next(g, i)
+			steer(g, i, d)
+			steer(loaded, i, d)
+		}
+		g.update()
+		loaded.update()
+		if got, want := loaded.state(), g.state(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%d ticks after loading: the saved game is at\n%+v\nwant\n%+v", tick+1, got, want)
+		}
+		if !slices.Equal(loaded.free, g.free) {
+			t.Fatalf("%d ticks after loading: the free lists differ", tick+1)
+		}
+	}
+	if got, want := loaded.rng.Uint64(), g.rng.Uint64(); got != want {
+		t.Errorf("next draw after loading is %d, want %d", got, want)
+	}
+	if !reflect.DeepEqual(loaded.log, g.log) {
+		t.Errorf("the replay carried through the save differs from the one kept in play")
+	}
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+)
+
+const (
+	roundPause = 3 * time.Second
+	matchPause = 5 * time.Second
+)
+
+type peer struct {
+	id      int
+	name    string
+	watcher bool
+	ready   bool
+	slot    int
+	wire    wire
+	out     chan *message
+	closed  bool
+}
+
+// send queues m without blocking, reporting false when the peer has fallen
+// too far behind to keep.
+func (p *peer) send(m *message) bool {
+	if p.closed {
+		return true
+	}
+	select {
+	case p.out <- m:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *peer) writeLoop() {
+	for m := range p.out {
+		if err := p.wire.send(m); err != nil {
+			break
+		}
+	}
+	p.wire.close()
+}
+
+type serverEvent struct {
+	peer *peer
+	msg  *message
+	gone bool
+}
+
+// server runs the authoritative game. Everything that touches the game or
+// the peer list happens on the goroutine running serve; connections only
+// feed it events.
+type server struct {
+	minPlayers int
+	maxPlayers int
+	rounds     int
+	foodCount  int
+	tick       time.Duration
+
+	// private servers host one web player's solo game and stop once
+	// nobody is left.
+	private bool
+
+	events   chan serverEvent
+	peers    []*peer
+	nextID   int
+	game     *Game
+	resumeAt time.Time
+	done     bool
+}
+
+func newServer(minPlayers, maxPlayers, rounds, foodCount int, tick time.Duration) *server {
+	return &server{
+		minPlayers: minPlayers,
+		maxPlayers: maxPlayers,
+		rounds:     rounds,
+		foodCount:  foodCount,
+		tick:       tick,
+		events:     make(chan serverEvent, 64),
+	}
+}
+
+func (s *server) serve(ln net.Listener) error {
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				errc <- err
+				return
+			}
+			go handshake(newLineConn(conn), s.events)
+		}
+	}()
+	return s.run(errc)
+}
+
+// run is the goroutine that owns the game. Connections reach it through
+// s.events; it returns whatever arrives on errc, or nil once a private
+// server has lost its player.
+func (s *server) run(errc <-chan error) error {
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+
+	for !s.done {
+		select {
+		case err := <-errc:
+			return err
+		case ev := <-s.events:
+			s.handle(ev)
+		case now := <-ticker.C:
+			s.step(now)
+		}
+	}
+	return nil
+}
+
+// handshake checks a new connection's hello and then feeds everything it
+// sends to events, ending with a gone event when it hangs up. It reports
+// whether the connection got past its hello; one that didn't sends nothing.
+func handshake(w wire, events chan<- serverEvent) bool {
+	m, err := w.recv()
+	if err != nil || m.Type != msgHello {
+		w.close()
+		return false
+	}
+	if m.Version != protocolVersion {
+		w.send(&message{Type: msgError, Error: fmt.Sprintf("unsupported protocol version %d, server speaks %d", m.Version, protocolVersion)})
+		w.close()
+		return false
+	}
+
+	p := &peer{name: m.Name, watcher: m.Role == roleSpectator, slot: -1, wire: w, out: make(chan *message, 64)}
+	go p.writeLoop()
+	events <- serverEvent{peer: p, msg: m}
+
+	for {
+		m, err := w.recv()
+		if err != nil {
+			events <- serverEvent{peer: p, gone: true}
+			return true
+		}
+		events <- serverEvent{peer: p, msg: m}
+	}
+}
+
+func (s *server) handle(ev serverEvent) {
+	p := ev.peer
+	switch {
+	case ev.gone:
+		s.remove(p)
+	case p.closed:
+	case ev.msg.Type == msgHello:
+		// The hello handshake read joins; any later one is ignored rather
+		// than letting a client into the lobby twice.
+		if p.id == 0 {
+			s.join(p)
+		}
+	case p.watcher:
+	case ev.msg.Type == msgReady:
+		if s.game == nil {
+			p.ready = ev.msg.Ready
+			s.broadcastLobby()
+			s.maybeStart()
+		}
+	case ev.msg.Type == msgDir:
+		if s.game != nil && p.slot >= 0 {
+			if d, ok := parseDir(ev.msg.Dir); ok {
+				s.game.changeDirection(p.slot, d.x, d.y)
+			}
+		}
+	}
+}
+
+func (s *server) join(p *peer) {
+	s.nextID++
+	p.id = s.nextID
+	if p.name == "" {
+		p.name = fmt.Sprintf("player%d", p.id)
+	}
+	s.peers = append(s.peers, p)
+	if p.watcher {
+		log.Printf("%s is watching (id %d)", p.name, p.id)
+	} else {
+		log.Printf("%s joined (id %d)", p.name, p.id)
+	}
+
+	s.send(p, &message{Type: msgWelcome, Version: protocolVersion, ID: p.id})
+	if s.game != nil {
+		s.game.spectators = s.spectators()
+		s.send(p, &message{Type: msgState, State: s.snapshot()})
+		return
+	}
+	s.broadcastLobby()
+	s.maybeStart()
+}
+
+// remove drops a peer that hung up or couldn't keep up. A player leaving
+// mid-match forfeits it: their snake dies and everyone returns to the
+// lobby once the result has been shown.
+func (s *server) remove(p *peer) {
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.out)
+	for i, q := range s.peers {
+		if q == p {
+			s.peers = append(s.peers[:i], s.peers[i+1:]...)
+			break
+		}
+	}
+	log.Printf("%s left", p.name)
+	if s.private && len(s.peers) == 0 {
+		s.done = true
+		return
+	}
+
+	if s.game == nil {
+		s.broadcastLobby()
+		s.maybeStart()
+		return
+	}
+	s.game.spectators = s.spectators()
+	if p.slot >= 0 {
+		prev := s.game.state()
+		s.game.kill(p.slot, causeLeft)
+		s.game.matchOver = true
+		s.resumeAt = time.Now().Add(matchPause)
+		s.broadcast(&message{Type: msgTick, Tick: s.game.diff(prev)})
+	}
+}
+
+func (s *server) send(p *peer, m *message) {
+	if !p.send(m) {
+		log.Printf("%s is not keeping up, dropping", p.name)
+		s.remove(p)
+	}
+}
+
+func (s *server) broadcast(m *message) {
+	for _, p := range append([]*peer(nil), s.peers...) {
+		s.send(p, m)
+	}
+}
+
+func (s *server) broadcastLobby() {
+	lobby := &wireLobby{MinPlayers: s.minPlayers, Spectators: s.spectators()}
+	for _, p := range s.peers {
+		if p.watcher {
+			continue
+		}
+		lobby.Players = append(lobby.Players, wirePeer{ID: p.id, Name: p.name, Ready: p.ready})
+	}
+	s.broadcast(&message{Type: msgLobby, Lobby: lobby})
+}
+
+// maybeStart begins a match once enough players are ready and nobody in the
+// lobby is still making up their mind, or the board is full.
+func (s *server) maybeStart() {
+	var ready []*peer
+	players := 0
+	for _, p := range s.peers {
+		if p.watcher {
+			continue
+		}
+		players++
+		if p.ready {
+			ready = append(ready, p)
+		}
+	}
+	if len(ready) < s.minPlayers {
+		return
+	}
+	if len(ready) < players && len(ready) < s.maxPlayers {
+		return
+	}
+	if len(ready) > s.maxPlayers {
+		ready = ready[:s.maxPlayers]
+	}
+
+	s.game = &Game{
+		foodCount: s.foodCount,
+		players:   len(ready),
+		rounds:    s.rounds,
+		remote:    true,
+		seed:      uint64(time.Now().UnixNano()),
+	}
+	s.game.init()
+	s.game.spectators = s.spectators()
+	for i, p := range ready {
+		p.slot = i
+		s.game.names = append(s.game.names, p.name)
+	}
+	log.Printf("match started with %d players", len(ready))
+	s.broadcast(&message{Type: msgState, State: s.snapshot()})
+}
+
+func (s *server) spectators() int {
+	n := 0
+	for _, p := range s.peers {
+		if p.watcher {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *server) snapshot() *wireState {
+	st := s.game.state()
+	for _, p := range s.peers {
+		if p.slot >= 0 {
+			st.Snakes[p.slot].ID = p.id
+		}
+	}
+	return st
+}
+
+func (s *server) step(now time.Time) {
+	g := s.game
+	if g == nil {
+		return
+	}
+	if g.gameOver {
+		if now.Before(s.resumeAt) {
+			return
+		}
+		if g.matchOver {
+			s.toLobby()
+			return
+		}
+		g.newRound()
+		s.broadcast(&message{Type: msgState, State: s.snapshot()})
+		return
+	}
+
+	prev := g.state()
+	g.update()
+	s.broadcast(&message{Type: msgTick, Tick: g.diff(prev)})
+	if g.matchOver {
+		s.resumeAt = now.Add(matchPause)
+	} else if g.gameOver {
+		s.resumeAt = now.Add(roundPause)
+	}
+}
+
+func (s *server) toLobby() {
+	log.Printf("match over, wins %s", s.game.tally())
+	s.game = nil
+	for _, p := range s.peers {
+		p.ready = false
+		p.slot = -1
+	}
+	s.broadcastLobby()
+}
+
+// matchFlags adds the match settings serve and web share to fs. Once fs is
+// parsed, the returned func checks them and makes a server that plays by
+// them.
+func matchFlags(fs *flag.FlagSet) func() *server {
+	minPlayers := fs.Int("min-players", 2, "ready players needed to start a match")
+	maxPlayers := fs.Int("max-players", 4, "snakes per match (at most 4)")
+	rounds := fs.Int("rounds", 3, "rounds per match (best of N)")
+	foodCount := fs.Int("food", 1, "number of food items on the board")
+	tick := fs.Duration("tick", 140*time.Millisecond, "time between game ticks")
+	return func() *server {
+		if *maxPlayers < 1 || *maxPlayers > 4 || *minPlayers < 1 || *minPlayers > *maxPlayers {
+			fmt.Fprintln(os.Stderr, "gsnake: need 1 <= --min-players <= --max-players <= 4")
+			os.Exit(2)
+		}
+		if *rounds < 1 || *foodCount < 1 || *tick <= 0 {
+			fmt.Fprintln(os.Stderr, "gsnake: --rounds, --food and --tick must be positive")
+			os.Exit(2)
+		}
+		return newServer(*minPlayers, *maxPlayers, *rounds, *foodCount, *tick)
+	}
+}
+
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := fs.String("listen", ":7777", "address to accept players on")
+	match := matchFlags(fs)
+	fs.Parse(args)
+	s := match()
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+	log.Printf("serving gsnake protocol v%d on %s", protocolVersion, ln.Addr())
+	if err := s.serve(ln); err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+}
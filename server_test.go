@@ -0,0 +1,155 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// startServer runs s on a free port on localhost until the test ends and
+// returns its address.
+func startServer(t *testing.T, s *server) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve(ln)
+	return ln.Addr().String()
+}
+
+// join dials addr as a player and hangs up when the test ends.
+func join(t *testing.T, addr, name string) *client {
+	t.Helper()
+	c, err := dial(addr, name, rolePlayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.w.close() })
+	return c
+}
+
+// next skips c's messages until one of type typ that ok accepts, failing
+// t if none comes within a few seconds. A nil ok accepts any.
+func next(t *testing.T, c *client, typ string, ok func(*message) bool) *message {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		got := make(chan *message, 1)
+		go func() {
+			m, err := c.w.recv()
+			if err != nil {
+				m = nil
+			}
+			got <- m
+		}()
+		select {
+		case m := <-got:
+			if m == nil {
+				t.Fatalf("connection closed waiting for a %s message", typ)
+			}
+			if m.Type == typ && (ok == nil || ok(m)) {
+				return m
+			}
+		case <-timeout:
+			t.Fatalf("no %s message within 5s", typ)
+		}
+	}
+}
+
+func TestServerMatch(t *testing.T) {
+	addr := startServer(t, newServer(2, 4, 3, 1, 100*time.Millisecond))
+	alice, bob := join(t, addr, "alice"), join(t, addr, "bob")
+	if alice.id == bob.id {
+		t.Fatalf("both players got id %d", alice.id)
+	}
+	next(t, alice, msgLobby, func(m *message) bool { return len(m.Lobby.Players) == 2 })
+
+	for _, c := range []*client{alice, bob} {
+		if err := c.w.send(&message{Type: msgReady, Ready: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range []*client{alice, bob} {
+		st := next(t, c, msgState, nil).State
+		if len(st.Snakes) != 2 || st.Snakes[0].ID != alice.id || st.Snakes[1].ID != bob.id {
+			t.Fatalf("match started with %+v, want alice's and bob's snakes", st.Snakes)
+		}
+		if tick := next(t, c, msgTick, nil).Tick; len(tick.Snakes) != 2 || tick.Snakes[0].Head == nil {
+			t.Fatalf("first tick %+v doesn't move both snakes", tick)
+		}
+	}
+
+	// Bob hanging up mid-match hands it to alice.
+	bob.w.close()
+	tick := next(t, alice, msgTick, func(m *message) bool { return m.Tick.Snakes[1].Dead }).Tick
+	if tick.Snakes[1].Cause != causeLeft || tick.Winner != 0 || !tick.MatchOver {
+		t.Errorf("after bob left: cause %q, winner %d, match over %v; want %q, 0 and true",
+			tick.Snakes[1].Cause, tick.Winner, tick.MatchOver, causeLeft)
+	}
+}
+
+func TestServerRepeatedHello(t *testing.T) {
+	addr := startServer(t, newServer(2, 4, 3, 1, 100*time.Millisecond))
+	alice := join(t, addr, "alice")
+	if err := alice.w.send(&message{Type: msgHello, Version: protocolVersion, Name: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	// The ready is read after the second hello, so the lobby that shows it
+	// shows whatever that hello did too.
+	if err := alice.w.send(&message{Type: msgReady, Ready: true}); err != nil {
+		t.Fatal(err)
+	}
+	lobby := next(t, alice, msgLobby, func(m *message) bool {
+		return len(m.Lobby.Players) > 0 && m.Lobby.Players[0].Ready
+	}).Lobby
+	if len(lobby.Players) != 1 {
+		t.Errorf("lobby has %+v after a second hello, want alice once", lobby.Players)
+	}
+}
+
+func TestServerIgnoresSpectators(t *testing.T) {
+	addr := startServer(t, newServer(2, 4, 3, 1, 50*time.Millisecond))
+	alice, bob := join(t, addr, "alice"), join(t, addr, "bob")
+	carol, err := dial(addr, "carol", roleSpectator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { carol.w.close() })
+
+	// A ready from a spectator neither puts them in the lobby nor counts
+	// towards starting: with only alice ready, nothing starts.
+	if err := carol.w.send(&message{Type: msgReady, Ready: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := alice.w.send(&message{Type: msgReady, Ready: true}); err != nil {
+		t.Fatal(err)
+	}
+	lobby := next(t, carol, msgLobby, func(m *message) bool {
+		return len(m.Lobby.Players) > 0 && m.Lobby.Players[0].Ready
+	}).Lobby
+	if len(lobby.Players) != 2 || lobby.Players[1].Ready || lobby.Spectators != 1 {
+		t.Fatalf("lobby is %+v, want alice ready, bob not and one spectator", lobby)
+	}
+
+	if err := bob.w.send(&message{Type: msgReady, Ready: true}); err != nil {
+		t.Fatal(err)
+	}
+	st := next(t, carol, msgState, nil).State
+	if len(st.Snakes) != 2 || st.Snakes[0].ID != alice.id || st.Snakes[1].ID != bob.id {
+		t.Fatalf("match started with %+v, want alice's and bob's snakes only", st.Snakes)
+	}
+
+	// Steering from a spectator goes nowhere: alice keeps heading right.
+	y := st.Snakes[0].Body[0][1]
+	for range 3 {
+		if err := carol.w.send(&message{Type: msgDir, Dir: "up"}); err != nil {
+			t.Fatal(err)
+		}
+		tick := next(t, carol, msgTick, nil).Tick
+		if h := tick.Snakes[0].Head; h == nil || h[1] != y {
+			t.Fatalf("alice's snake moved to %v on tick %d, want it still on row %d", h, tick.Tick, y)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// audience lets spectators watch a game that isn't run by a server, such as
+// local play. Like the server it is driven from the goroutine that owns
+// the game: connections arrive on events and frames leave via broadcast.
+type audience struct {
+	events   chan serverEvent
+	watchers []*peer
+	nextID   int
+}
+
+func newAudience(ln net.Listener) *audience {
+	a := &audience{events: make(chan serverEvent, 64)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go handshake(newLineConn(conn), a.events)
+		}
+	}()
+	return a
+}
+
+// handle admits spectators and turns away anyone hoping to play. Nothing a
+// watcher sends after its hello is looked at, another hello included.
+func (a *audience) handle(ev serverEvent, g *Game) {
+	p := ev.peer
+	switch {
+	case ev.gone:
+		a.remove(p)
+	case p.closed || ev.msg.Type != msgHello || p.id != 0:
+	case !p.watcher:
+		p.send(&message{Type: msgError, Error: "this is a local game, use gsnake watch"})
+		p.closed = true
+		close(p.out)
+	default:
+		a.nextID++
+		p.id = a.nextID
+		a.watchers = append(a.watchers, p)
+		a.send(p, &message{Type: msgWelcome, Version: protocolVersion, ID: p.id})
+		a.send(p, &message{Type: msgState, State: g.state()})
+	}
+	g.spectators = len(a.watchers)
+}
+
+func (a *audience) remove(p *peer) {
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.out)
+	for i, q := range a.watchers {
+		if q == p {
+			a.watchers = append(a.watchers[:i], a.watchers[i+1:]...)
+			break
+		}
+	}
+}
+
+func (a *audience) send(p *peer, m *message) {
+	if !p.send(m) {
+		a.remove(p)
+	}
+}
+
+func (a *audience) broadcast(m *message) {
+	for _, p := range append([]*peer(nil), a.watchers...) {
+		a.send(p, m)
+	}
+}
+
+// focusPanel describes the snake a spectator has picked out, below the
+// board.
+func (c *client) focusPanel() string {
+	g := c.game
+	if len(g.snakes) == 0 {
+		return ""
+	}
+	c.focus = (c.focus%len(g.snakes) + len(g.snakes)) % len(g.snakes)
+	s := g.snakes[c.focus]
+	status := "alive"
+	if s.dead {
+		status = causeText[s.cause]
+	}
+	line := fmt.Sprintf("%s%s%s | score %d | length %d | %s",
+		theme.snakeColor(c.focus), g.label(c.focus), colorReset, s.score, s.body.len(), status)
+	if len(g.wins) > c.focus && g.players > 1 {
+		line += fmt.Sprintf(" | %d rounds won", g.wins[c.focus])
+	}
+	return "\nWatching " + line + "\nLeft/Right or 1-4 to switch player, " + keyName(keys.Quit) + " to quit"
+}
+
+func runWatch(args []string) {
+	cfg := mustLoadConfig()
+	cfg.use()
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	name := fs.String("name", cfg.Name, "name shown in the server log")
+	record := fs.String("record", "", "record what's drawn to an asciinema cast `file`")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: gsnake watch [--name NAME] [--record FILE] host:port")
+		os.Exit(2)
+	}
+
+	c, err := dial(fs.Arg(0), *name, roleSpectator)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+	defer c.w.close()
+	out, rec, err := recordTo(c.term, *record, "watching gsnake at "+fs.Arg(0), defaultWidth, defaultHeight)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+	if rec != nil {
+		defer rec.Close()
+	}
+	c.out = out
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+
+	restore := c.term.Raw()
+	go func() {
+		<-sig
+		restore()
+		fmt.Println("\nStopped watching!")
+		os.Exit(0)
+	}()
+
+	err = c.play()
+	restore()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\ngsnake:", err)
+		os.Exit(1)
+	}
+	fmt.Println("\nStopped watching!")
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAudienceIgnoresWatchers(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	a := newAudience(ln)
+	// The test is the game loop: it hands the audience every event itself,
+	// so it knows exactly when the watcher's messages have been dealt with.
+	handle := func(g *Game) {
+		t.Helper()
+		select {
+		case ev := <-a.events:
+			a.handle(ev, g)
+		case <-time.After(5 * time.Second):
+			t.Fatal("nothing from the watcher within 5s")
+		}
+	}
+
+	g := &Game{players: 1, foodCount: 1, seed: 1}
+	g.init()
+	joined := make(chan *client, 1)
+	go func() {
+		c, err := dial(ln.Addr().String(), "carol", roleSpectator)
+		if err != nil {
+			t.Error(err)
+		}
+		joined <- c
+	}()
+	handle(g)
+	c := <-joined
+	if c == nil {
+		t.FailNow()
+	}
+	defer c.w.close()
+
+	before := g.state()
+	for _, m := range []*message{{Type: msgReady, Ready: true}, {Type: msgDir, Dir: "up"}} {
+		if err := c.w.send(m); err != nil {
+			t.Fatal(err)
+		}
+		handle(g)
+	}
+	if after := g.state(); !reflect.DeepEqual(after, before) {
+		t.Fatalf("the watcher changed the game from %+v to %+v", before, after)
+	}
+	if d := g.snakes[0].direction; d != (Point{1, 0}) {
+		t.Errorf("snake heading %v after the watcher sent up, want it still going right", d)
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sshExtraLines is how many lines a frame needs besides the board: the
+// status line above it and the game-over message below.
+const sshExtraLines = 6
+
+func sshHostKeyPath() string {
+	return filepath.Join(dataDir(), "ssh_host_ed25519_key")
+}
+
+// sshPlayersPath is an authorized_keys file whose comments are the names
+// players with those keys go by.
+func sshPlayersPath() string {
+	return filepath.Join(filepath.Dir(configPath()), "ssh_players")
+}
+
+// sshHostKey loads the server's host key, making one the first time so
+// players' ssh clients see the same server from then on.
+func sshHostKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return ssh.ParsePrivateKey(data)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	block, err := ssh.MarshalPrivateKey(key, "gsnake host key")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		return nil, err
+	}
+	log.Printf("made a new host key in %s", path)
+	return ssh.NewSignerFromKey(key)
+}
+
+// sshPlayers reads the registered keys, each mapped to its player's name.
+// A missing file just means nobody has registered.
+func sshPlayers(path string) (map[string]string, error) {
+	players := make(map[string]string)
+	rest, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return players, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// ParseAuthorizedKey skips lines it can't read and only fails once no
+	// keys are left.
+	for len(rest) > 0 {
+		key, name, _, next, err := ssh.ParseAuthorizedKey(rest)
+		if err != nil {
+			break
+		}
+		if name = playerName(name); name == "" {
+			return nil, fmt.Errorf("%s: the key %s has no name after it", path, ssh.FingerprintSHA256(key))
+		}
+		players[string(key.Marshal())] = name
+		rest = next
+	}
+	return players, nil
+}
+
+// playerName makes s fit to show to others and send to a leaderboard.
+func playerName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(s))
+	if len(s) > 32 {
+		s = s[:32]
+	}
+	return s
+}
+
+// sshConfig lets in players with a registered key under their own name and,
+// when guests are allowed, anyone else through keyboard-interactive with
+// no questions asked, named after the user they logged in as. Keys are
+// tried first, so a registered player is never taken for a guest.
+func sshConfig(host ssh.Signer, playersPath string, guests bool) *ssh.ServerConfig {
+	cfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			players, err := sshPlayers(playersPath)
+			if err != nil {
+				log.Print(err)
+				return nil, errors.New("can't read the registered keys")
+			}
+			name, ok := players[string(key.Marshal())]
+			if !ok {
+				return nil, errors.New("key isn't registered")
+			}
+			return &ssh.Permissions{Extensions: map[string]string{"name": name}}, nil
+		},
+	}
+	if guests {
+		cfg.KeyboardInteractiveCallback = func(conn ssh.ConnMetadata, _ ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			name := playerName("guest-" + conn.User())
+			return &ssh.Permissions{Extensions: map[string]string{"name": name}}, nil
+		}
+	}
+	cfg.AddHostKey(host)
+	return cfg
+}
+
+// sshTerminal is the pty an SSH client asked for: keys arrive on the
+// session's channel and frames go back on it.
+type sshTerminal struct {
+	ch ssh.Channel
+
+	mu         sync.Mutex
+	cols, rows int
+}
+
+// ptySize is a window-change's payload, and what a pty-req says about size.
+type ptySize struct {
+	Cols, Rows, Width, Height uint32
+}
+
+func (t *sshTerminal) Read(p []byte) (int, error) { return t.ch.Read(p) }
+
+// Write turns the newlines frames end their lines with into the carriage
+// return and line feed a terminal needs when no tty driver sits between.
+func (t *sshTerminal) Write(p []byte) (int, error) {
+	if _, err := t.ch.Write([]byte(strings.ReplaceAll(string(p), "\n", "\r\n"))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (t *sshTerminal) Size() (cols, rows int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cols, t.rows
+}
+
+func (t *sshTerminal) resize(size ptySize) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cols, t.rows = int(size.Cols), int(size.Rows)
+}
+
+// Raw has nothing to do: the player's own ssh client already put their
+// terminal in raw mode.
+func (t *sshTerminal) Raw() func() { return func() {} }
+
+type sshServer struct {
+	cfg    *Config
+	config *ssh.ServerConfig
+	board  leaderboard
+}
+
+func (s *sshServer) serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *sshServer) handle(conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	sc, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	defer sc.Close()
+	name := sc.Permissions.Extensions["name"]
+	log.Printf("%s connected from %s", name, sc.RemoteAddr())
+	defer log.Printf("%s left", name)
+
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "only sessions here")
+			continue
+		}
+		ch, requests, err := nc.Accept()
+		if err != nil {
+			return
+		}
+		go s.session(ch, requests, name)
+	}
+}
+
+// session waits for a pty and a shell, then plays a game sized to the
+// player's terminal until they quit.
+func (s *sshServer) session(ch ssh.Channel, requests <-chan *ssh.Request, name string) {
+	defer ch.Close()
+	var term *sshTerminal
+	for req := range requests {
+		switch req.Type {
+		case "pty-req":
+			var pty struct {
+				Term                      string
+				Cols, Rows, Width, Height uint32
+				Modes                     string
+			}
+			if ssh.Unmarshal(req.Payload, &pty) != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			term = &sshTerminal{ch: ch}
+			term.resize(ptySize{pty.Cols, pty.Rows, pty.Width, pty.Height})
+			req.Reply(true, nil)
+		case "window-change":
+			// The board keeps the size it started with, but every frame
+			// redraws the whole screen anyway.
+			var size ptySize
+			if term != nil && ssh.Unmarshal(req.Payload, &size) == nil {
+				term.resize(size)
+			}
+		case "shell":
+			req.Reply(true, nil)
+			go ssh.DiscardRequests(requests)
+			code := uint32(1)
+			if term == nil {
+				fmt.Fprint(ch, "gsnake needs a terminal, try ssh -t\r\n")
+			} else {
+				code = s.play(term, name)
+			}
+			ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Code uint32 }{code}))
+			return
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+// play runs one player's game on term and returns the exit status to
+// hand their ssh client.
+func (s *sshServer) play(term Terminal, name string) uint32 {
+	cols, rows := term.Size()
+	width, height := min(cols, maxBoardWidth), min(rows-sshExtraLines, maxBoardHeight)
+	if width < minBoardWidth || height < minBoardHeight {
+		fmt.Fprintf(term, "gsnake needs a terminal of at least %dx%d, try a bigger window\n", minBoardWidth, minBoardHeight+sshExtraLines)
+		return 1
+	}
+
+	inputs := make(chan input, 16)
+	go func() {
+		readInput(term, inputs)
+		select {
+		case inputs <- input{key: 'q'}:
+		default:
+		}
+	}()
+	game := Game{
+		width:     width,
+		height:    height,
+		foodCount: s.cfg.Food,
+		players:   1,
+		level:     s.cfg.Level,
+		names:     []string{name},
+		seed:      uint64(time.Now().UnixNano()),
+		log:       &replay{},
+	}
+	game.init()
+	session := &localSession{inputs: inputs, out: term, board: s.board, name: name, hosted: true}
+	session.play(&game, s.cfg.Tick)
+	fmt.Fprintln(term, "\nThx for playing!")
+	return 0
+}
+
+func runSSHServe(args []string) {
+	cfg := mustLoadConfig()
+	fs := flag.NewFlagSet("ssh-serve", flag.ExitOnError)
+	port := fs.Int("port", 2222, "port to accept ssh connections on")
+	hostKey := fs.String("host-key", sshHostKeyPath(), "`file` with the server's host key, made on first run")
+	players := fs.String("players", sshPlayersPath(), "authorized_keys `file` of registered players, each key followed by the player's name")
+	guests := fs.Bool("guests", true, "let in players without a registered key, named guest-USER")
+	fs.Parse(args)
+	cfg.use()
+
+	if _, err := sshPlayers(*players); err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(2)
+	}
+	host, err := sshHostKey(*hostKey)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+	board, err := openLeaderboard(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", *port))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+	log.Printf("ssh on %s, host key %s", ln.Addr(), ssh.FingerprintSHA256(host.PublicKey()))
+	s := &sshServer{cfg: cfg, config: sshConfig(host, *players, *guests), board: board}
+	if err := s.serve(ln); err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Modes a finished game is filed under in the stats.
+const (
+	modeSolo      = "solo"
+	modeVersus    = "versus"
+	modeAutopilot = "autopilot"
+	modeBot       = "bot"
+	modeDaily     = "daily"
+)
+
+// Causes the stats use on top of the engine's: a full board, and the snake
+// still alive when someone else ended a versus round.
+const (
+	causeWin      = "win"
+	causeSurvived = "survived"
+)
+
+// statEntry is one snake's result in one finished game or round.
+type statEntry struct {
+	Date     time.Time     `json:"date"`
+	Mode     string        `json:"mode"`
+	Player   int           `json:"player,omitempty"`
+	Score    int           `json:"score"`
+	Length   int           `json:"length"`
+	Ticks    int           `json:"ticks"`
+	Duration time.Duration `json:"duration"`
+	Cause    string        `json:"cause"`
+	Seed     uint64        `json:"seed"`
+	Resumed  bool          `json:"resumed,omitempty"`
+}
+
+func statsPath() string {
+	return filepath.Join(dataDir(), "stats.jsonl")
+}
+
+// recordStats appends the result of the round g just finished, one line per
+// snake, to the stats file.
+func recordStats(g *Game, mode string) error {
+	if err := os.MkdirAll(dataDir(), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(statsPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var lines []byte
+	for i, s := range g.snakes {
+		e := statEntry{
+			Date:     now,
+			Mode:     mode,
+			Score:    s.score,
+			Length:   s.body.len(),
+			Ticks:    g.ticks,
+			Duration: g.elapsed,
+			Cause:    s.cause,
+			Seed:     g.seed,
+			Resumed:  g.resumed,
+		}
+		switch {
+		case g.won:
+			e.Cause = causeWin
+		case !s.dead:
+			e.Cause = causeSurvived
+		}
+		if g.players > 1 {
+			e.Player = i + 1
+		}
+		line, err := json.Marshal(e)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+	if _, err := f.Write(lines); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadStats reads every entry on file. A torn last line, left by a crash
+// mid-write, is skipped rather than failing the lot.
+func loadStats() ([]statEntry, error) {
+	f, err := os.Open(statsPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []statEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e statEntry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// dayStreaks returns the longest run of consecutive days with at least one
+// game, and the run that ends today or yesterday.
+func dayStreaks(entries []statEntry, today time.Time) (best, current int) {
+	days := make(map[string]bool)
+	for _, e := range entries {
+		days[e.Date.Local().Format(time.DateOnly)] = true
+	}
+	var sorted []string
+	for d := range days {
+		sorted = append(sorted, d)
+	}
+	sort.Strings(sorted)
+
+	run := 0
+	var last time.Time
+	for _, d := range sorted {
+		t, _ := time.ParseInLocation(time.DateOnly, d, time.Local)
+		if run > 0 && t.Equal(last.AddDate(0, 0, 1)) {
+			run++
+		} else {
+			run = 1
+		}
+		last = t
+		best = max(best, run)
+	}
+	yesterday := today.AddDate(0, 0, -1).Format(time.DateOnly)
+	if n := len(sorted); n > 0 && (sorted[n-1] == today.Format(time.DateOnly) || sorted[n-1] == yesterday) {
+		current = run
+	}
+	return best, current
+}
+
+// histogram draws how scores are spread as horizontal bars, at most ten
+// buckets of equal width.
+func histogram(scores []int, width int) string {
+	if len(scores) == 0 {
+		return ""
+	}
+	top := 0
+	for _, s := range scores {
+		top = max(top, s)
+	}
+	bucket := top/10 + 1
+	counts := make([]int, top/bucket+1)
+	most := 0
+	for _, s := range scores {
+		counts[s/bucket]++
+		most = max(most, counts[s/bucket])
+	}
+
+	var b strings.Builder
+	for i, n := range counts {
+		label := fmt.Sprintf("%d", i*bucket)
+		if bucket > 1 {
+			label = fmt.Sprintf("%d-%d", i*bucket, (i+1)*bucket-1)
+		}
+		bar := strings.Repeat("█", n*width/most)
+		if n > 0 && bar == "" {
+			bar = "▏"
+		}
+		fmt.Fprintf(&b, "  %9s │%s %d\n", label, bar, n)
+	}
+	return b.String()
+}
+
+func printStats(entries []statEntry, today time.Time) {
+	if len(entries) == 0 {
+		fmt.Println("no games yet")
+		return
+	}
+
+	var score, length, ticks int
+	var played time.Duration
+	best, longest := entries[0], entries[0]
+	causes := make(map[string]int)
+	modes := make(map[string]int)
+	scores := make([]int, len(entries))
+	for i, e := range entries {
+		score += e.Score
+		length += e.Length
+		ticks += e.Ticks
+		played += e.Duration
+		causes[e.Cause]++
+		modes[e.Mode]++
+		scores[i] = e.Score
+		if e.Score > best.Score {
+			best = e
+		}
+		if e.Ticks > longest.Ticks {
+			longest = e
+		}
+	}
+	n := float64(len(entries))
+	bestStreak, current := dayStreaks(entries, today)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "games\t%d\n", len(entries))
+	var byMode []string
+	for m, c := range modes {
+		byMode = append(byMode, fmt.Sprintf("%s %d", m, c))
+	}
+	sort.Strings(byMode)
+	fmt.Fprintf(w, "by mode\t%s\n", strings.Join(byMode, ", "))
+	fmt.Fprintf(w, "time played\t%s\n", played.Round(time.Second))
+	fmt.Fprintf(w, "total score\t%d\n", score)
+	fmt.Fprintf(w, "average score\t%.1f\n", float64(score)/n)
+	fmt.Fprintf(w, "average length\t%.1f\n", float64(length)/n)
+	fmt.Fprintf(w, "average ticks\t%.0f\n", float64(ticks)/n)
+	fmt.Fprintf(w, "average game\t%s\n", (played / time.Duration(len(entries))).Round(time.Second))
+	fmt.Fprintf(w, "best score\t%d (%s, %s)\n", best.Score, best.Mode, best.Date.Local().Format(time.DateOnly))
+	fmt.Fprintf(w, "longest game\t%d ticks (%s, %s)\n", longest.Ticks, longest.Mode, longest.Date.Local().Format(time.DateOnly))
+	fmt.Fprintf(w, "day streak\t%d best, %d now\n", bestStreak, current)
+	w.Flush()
+
+	fmt.Println("\nscores")
+	fmt.Print(histogram(scores, 40))
+
+	fmt.Println("\nhow games ended")
+	var names []string
+	for c := range causes {
+		names = append(names, c)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if causes[names[i]] != causes[names[j]] {
+			return causes[names[i]] > causes[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, c := range names {
+		text := causeText[c]
+		switch c {
+		case causeWin:
+			text = "filled the board"
+		case causeSurvived:
+			text = "outlived the other snake"
+		}
+		fmt.Fprintf(w, "  %s\t%d\t%.0f%%\n", text, causes[c], float64(causes[c])*100/n)
+	}
+	w.Flush()
+}
+
+func runStats(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	mode := fs.String("mode", "", "only count games of this mode: solo, versus, autopilot, bot or daily")
+	since := fs.String("since", "", "only count games from this `date` on, YYYY-MM-DD")
+	until := fs.String("until", "", "only count games up to and including this `date`, YYYY-MM-DD")
+	fs.Parse(args)
+
+	switch *mode {
+	case "", modeSolo, modeVersus, modeAutopilot, modeBot, modeDaily:
+	default:
+		fmt.Fprintln(os.Stderr, "gsnake: --mode must be solo, versus, autopilot, bot or daily")
+		os.Exit(2)
+	}
+	var from, to time.Time
+	for _, d := range []struct {
+		flag  string
+		value string
+		into  *time.Time
+	}{{"--since", *since, &from}, {"--until", *until, &to}} {
+		if d.value == "" {
+			continue
+		}
+		t, err := time.ParseInLocation(time.DateOnly, d.value, time.Local)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gsnake: %s wants a date like 2026-01-31\n", d.flag)
+			os.Exit(2)
+		}
+		*d.into = t
+	}
+	if !to.IsZero() {
+		to = to.AddDate(0, 0, 1)
+	}
+
+	entries, err := loadStats()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+	var kept []statEntry
+	for _, e := range entries {
+		if *mode != "" && e.Mode != *mode {
+			continue
+		}
+		if !from.IsZero() && e.Date.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !e.Date.Before(to) {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	printStats(kept, time.Now())
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Terminal is what a game is played on: keystrokes are read from it and
+// frames are written to it. The terminal gsnake was started in is one,
+// every SSH session gets its own, and tests play on buffers.
+type Terminal interface {
+	io.Reader
+	io.Writer
+	// Size is how many columns and rows there are to draw in, or zeros
+	// when that isn't known.
+	Size() (cols, rows int)
+	// Raw hands over keys as they're pressed, without echoing them, and
+	// returns what puts the terminal back the way it was.
+	Raw() (restore func())
+}
+
+type termios struct {
+	Iflag  uint32
+	Oflag  uint32
+	Cflag  uint32
+	Lflag  uint32
+	Cc     [20]uint8
+	Ispeed uint32
+	Ospeed uint32
+}
+
+func tcgetattr(fd int) *termios {
+	var t termios
+	syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(0x5401), uintptr(unsafe.Pointer(&t)))
+	return &t
+}
+
+func tcsetattr(fd int, t *termios) {
+	syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(0x5402), uintptr(unsafe.Pointer(t)))
+}
+
+// tty is the terminal gsnake was started in: keys from stdin, frames to
+// stdout.
+type tty struct{}
+
+func (tty) Read(p []byte) (int, error)  { return os.Stdin.Read(p) }
+func (tty) Write(p []byte) (int, error) { return os.Stdout.Write(p) }
+
+func (tty) Size() (cols, rows int) {
+	var ws struct{ Row, Col, X, Y uint16 }
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, 1, syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws))); errno != 0 {
+		return 0, 0
+	}
+	return int(ws.Col), int(ws.Row)
+}
+
+// Raw leaves signals alone, so Ctrl-C still interrupts.
+func (tty) Raw() func() {
+	original := tcgetattr(0)
+	raw := *original
+	raw.Lflag &^= 0x00000002 | 0x00000008
+	raw.Cc[6] = 1
+	raw.Cc[5] = 0
+	tcsetattr(0, &raw)
+	return func() { tcsetattr(0, original) }
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"strings"
+)
+
+// Theme is the set of colors a game is drawn in, both on the terminal and
+// when it's exported to an image.
+type Theme struct {
+	Name       string
+	Background color.RGBA
+	Wall       color.RGBA
+	Food       color.RGBA
+	Dead       color.RGBA
+	Snakes     []color.RGBA
+	// term overrides the escape codes snakes get on the terminal. Without
+	// it they're drawn in 24-bit color from Snakes.
+	term []string
+}
+
+func rgb(r, g, b uint8) color.RGBA { return color.RGBA{r, g, b, 0xff} }
+
+var themes = map[string]*Theme{
+	"classic": {
+		Name:       "classic",
+		Background: rgb(0x00, 0x00, 0x00),
+		Wall:       rgb(0xe5, 0xe5, 0xe5),
+		Food:       rgb(0xe5, 0xe5, 0xe5),
+		Dead:       rgb(0xcd, 0x00, 0x00),
+		Snakes:     []color.RGBA{rgb(0x00, 0xcd, 0x00), rgb(0x00, 0xcd, 0xcd), rgb(0xcd, 0x00, 0xcd), rgb(0xcd, 0xcd, 0x00)},
+		term:       []string{"\033[32m", "\033[36m", "\033[35m", "\033[33m"},
+	},
+	"solarized": {
+		Name:       "solarized",
+		Background: rgb(0x00, 0x2b, 0x36),
+		Wall:       rgb(0x58, 0x6e, 0x75),
+		Food:       rgb(0xb5, 0x89, 0x00),
+		Dead:       rgb(0xdc, 0x32, 0x2f),
+		Snakes:     []color.RGBA{rgb(0x85, 0x99, 0x00), rgb(0x26, 0x8b, 0xd2), rgb(0xd3, 0x36, 0x82), rgb(0x2a, 0xa1, 0x98)},
+	},
+	"paper": {
+		Name:       "paper",
+		Background: rgb(0xfa, 0xf8, 0xf0),
+		Wall:       rgb(0x33, 0x33, 0x33),
+		Food:       rgb(0xd9, 0x48, 0x1c),
+		Dead:       rgb(0x99, 0x99, 0x99),
+		Snakes:     []color.RGBA{rgb(0x2e, 0x7d, 0x32), rgb(0x15, 0x65, 0xc0), rgb(0x6a, 0x1b, 0x9a), rgb(0xef, 0x6c, 0x00)},
+	},
+}
+
+// theme is the one in use for this run, picked with --theme.
+var theme = themes["classic"]
+
+func themeNames() string {
+	var names []string
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// snakeColor is the terminal escape snake i is drawn with.
+func (t *Theme) snakeColor(i int) string {
+	if len(t.term) > 0 {
+		return t.term[i%len(t.term)]
+	}
+	c := t.Snakes[i%len(t.Snakes)]
+	return fmt.Sprintf("\033[38;2;%d;%d;%dm", c.R, c.G, c.B)
+}
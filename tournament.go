@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ", ") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+type gameResult struct {
+	Bot      string `json:"bot"`
+	Game     int    `json:"game"`
+	Seed     uint64 `json:"seed"`
+	Score    int    `json:"score"`
+	Ticks    int    `json:"ticks"`
+	Won      bool   `json:"won"`
+	Cause    string `json:"cause,omitempty"`
+	Timeouts int    `json:"timeouts"`
+	Crashed  bool   `json:"crashed"`
+	Error    string `json:"error,omitempty"`
+}
+
+type botSummary struct {
+	Bot         string  `json:"bot"`
+	Games       int     `json:"games"`
+	MeanScore   float64 `json:"mean_score"`
+	MedianScore float64 `json:"median_score"`
+	MeanTicks   float64 `json:"mean_ticks"`
+	WinRate     float64 `json:"win_rate"`
+	Timeouts    int     `json:"timeouts"`
+	Crashes     int     `json:"crashes"`
+}
+
+type tournamentReport struct {
+	Seed     uint64       `json:"seed"`
+	Games    int          `json:"games_per_bot"`
+	MaxTicks int          `json:"max_ticks"`
+	Summary  []botSummary `json:"summary"`
+	Results  []gameResult `json:"results"`
+}
+
+// newController builds what a tournament entry names: "autopilot:astar"
+// for a built-in strategy, anything else is a bot command.
+func newController(spec string, timeout time.Duration, g *Game) (Controller, error) {
+	if name, ok := strings.CutPrefix(spec, "autopilot:"); ok {
+		strategy, ok := strategies[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown strategy %q", name)
+		}
+		return strategy(), nil
+	}
+	return startBot(spec, timeout, g)
+}
+
+func playTournamentGame(spec string, game int, seed uint64, foodCount, maxTicks int, timeout time.Duration) gameResult {
+	r := gameResult{Bot: spec, Game: game, Seed: seed}
+	g := &Game{foodCount: foodCount, seed: seed}
+	g.init()
+	c, err := newController(spec, timeout, g)
+	if err != nil {
+		r.Crashed = true
+		r.Error = err.Error()
+		return r
+	}
+	playSolo(g, c, maxTicks, nil)
+
+	s := g.snakes[0]
+	r.Score = s.score
+	r.Ticks = g.ticks
+	r.Won = g.won
+	r.Cause = s.cause
+	if b, ok := c.(*bot); ok {
+		r.Timeouts = b.timeouts
+		if b.err != nil {
+			r.Crashed = true
+			r.Error = b.err.Error()
+		}
+	}
+	return r
+}
+
+// runTournament plays games seeded games for every entry, spread over
+// parallel workers. Game i of every entry uses seed+i, so entries face the
+// same food draws and a rerun with the same seed gives the same report.
+func runTournament(specs []string, games int, seed uint64, foodCount, maxTicks, parallel int, timeout time.Duration) *tournamentReport {
+	results := make([]gameResult, len(specs)*games)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				spec, game := specs[i/games], i%games
+				results[i] = playTournamentGame(spec, game, seed+uint64(game), foodCount, maxTicks, timeout)
+			}
+		}()
+	}
+	for i := range results {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	report := &tournamentReport{Seed: seed, Games: games, MaxTicks: maxTicks, Results: results}
+	for b, spec := range specs {
+		report.Summary = append(report.Summary, summarize(spec, results[b*games:(b+1)*games]))
+	}
+	return report
+}
+
+func summarize(spec string, results []gameResult) botSummary {
+	sum := botSummary{Bot: spec, Games: len(results)}
+	scores := make([]int, len(results))
+	wins := 0
+	for i, r := range results {
+		scores[i] = r.Score
+		sum.MeanScore += float64(r.Score)
+		sum.MeanTicks += float64(r.Ticks)
+		sum.Timeouts += r.Timeouts
+		if r.Won {
+			wins++
+		}
+		if r.Crashed {
+			sum.Crashes++
+		}
+	}
+	if n := float64(len(results)); n > 0 {
+		sum.MeanScore /= n
+		sum.MeanTicks /= n
+		sum.WinRate = float64(wins) / n
+	}
+	sort.Ints(scores)
+	switch n := len(scores); {
+	case n == 0:
+	case n%2 == 1:
+		sum.MedianScore = float64(scores[n/2])
+	default:
+		sum.MedianScore = float64(scores[n/2-1]+scores[n/2]) / 2
+	}
+	return sum
+}
+
+func (r *tournamentReport) printTable() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "bot\tgames\tmean score\tmedian score\tmean ticks\twin rate\ttimeouts\tcrashes")
+	for _, s := range r.Summary {
+		fmt.Fprintf(w, "%s\t%d\t%.2f\t%.1f\t%.1f\t%.0f%%\t%d\t%d\n",
+			s.Bot, s.Games, s.MeanScore, s.MedianScore, s.MeanTicks, s.WinRate*100, s.Timeouts, s.Crashes)
+	}
+	w.Flush()
+}
+
+func (r *tournamentReport) writeCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	w.Write([]string{"bot", "game", "seed", "score", "ticks", "won", "cause", "timeouts", "crashed", "error"})
+	for _, g := range r.Results {
+		w.Write([]string{
+			g.Bot,
+			strconv.Itoa(g.Game),
+			strconv.FormatUint(g.Seed, 10),
+			strconv.Itoa(g.Score),
+			strconv.Itoa(g.Ticks),
+			strconv.FormatBool(g.Won),
+			g.Cause,
+			strconv.Itoa(g.Timeouts),
+			strconv.FormatBool(g.Crashed),
+			g.Error,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (r *tournamentReport) writeJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func runTournamentCmd(args []string) {
+	fs := flag.NewFlagSet("tournament", flag.ExitOnError)
+	var specs stringList
+	fs.Var(&specs, "bot", "bot `command` to enter, or autopilot:NAME for a built-in strategy (repeatable)")
+	games := fs.Int("games", 10, "games per bot")
+	seed := fs.Uint64("seed", 1, "seed of the first game; game i uses seed+i")
+	foodCount := fs.Int("food", 1, "number of food items on the board")
+	maxTicks := fs.Int("max-ticks", 10000, "end a game that runs this many ticks")
+	parallel := fs.Int("parallel", runtime.NumCPU(), "games played at once")
+	timeout := fs.Duration("timeout", 100*time.Millisecond, "time a bot gets to answer each tick")
+	csvPath := fs.String("csv", "", "write every game's result to this CSV `file`")
+	jsonPath := fs.String("json", "", "write the summary and every game's result to this JSON `file`")
+	fs.Parse(args)
+
+	if len(specs) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: gsnake tournament --bot COMMAND [--bot COMMAND...] [flags]")
+		os.Exit(2)
+	}
+	if *games < 1 || *foodCount < 1 || *maxTicks < 1 || *parallel < 1 || *timeout <= 0 {
+		fmt.Fprintln(os.Stderr, "gsnake: --games, --food, --max-ticks, --parallel and --timeout must be positive")
+		os.Exit(2)
+	}
+
+	report := runTournament(specs, *games, *seed, *foodCount, *maxTicks, *parallel, *timeout)
+	report.printTable()
+	if *csvPath != "" {
+		if err := report.writeCSV(*csvPath); err != nil {
+			fmt.Fprintln(os.Stderr, "gsnake:", err)
+			os.Exit(1)
+		}
+	}
+	if *jsonPath != "" {
+		if err := report.writeJSON(*jsonPath); err != nil {
+			fmt.Fprintln(os.Stderr, "gsnake:", err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// claim is what someone says a replay scores for player 1.
+type claim struct {
+	Score  int
+	Length int
+	Ticks  int
+}
+
+// snakeResult is how one snake finished a replayed game.
+type snakeResult struct {
+	Score  int
+	Length int
+	Dead   bool
+	Cause  string
+}
+
+// verdict is what re-simulating a replay found: how the game really ended,
+// and everything wrong with the replay or the claim made for it.
+type verdict struct {
+	Rounds   int
+	Ticks    int
+	Snakes   []snakeResult
+	Problems []string
+}
+
+func (v *verdict) ok() bool { return len(v.Problems) == 0 }
+
+func (v *verdict) problem(format string, args ...any) {
+	v.Problems = append(v.Problems, fmt.Sprintf(format, args...))
+}
+
+// verifyReplay plays r through the engine and checks it on the way. The
+// game only ever records turns it accepted, so a move the engine would
+// have turned down, like a reversal, means the replay was written by
+// something other than the game. With c set, the claimed result has to be
+// exactly what the replay scores.
+func verifyReplay(r *replay, c *claim) *verdict {
+	v := &verdict{}
+	if r.Version != replayVersion {
+		v.problem("replay version %d, this build reads %d", r.Version, replayVersion)
+		return v
+	}
+	g, err := r.run(func(*Game) {}, func(g *Game, i int, m replayMove, d Point) {
+		s := g.snakes[m.Player]
+		switch moved := s.moved(); {
+		case d.x == -moved.x && d.y == -moved.y:
+			v.problem("move %d: P%d reverses from %s to %s at round %d tick %d, which the game doesn't allow",
+				i+1, m.Player+1, dirNames[moved], m.Dir, m.Round, m.Tick)
+		case d == s.direction:
+			v.problem("move %d: P%d turns %s at round %d tick %d while already heading that way, which the game never records",
+				i+1, m.Player+1, m.Dir, m.Round, m.Tick)
+		}
+	})
+	if err != nil {
+		v.problem("%v", err)
+		return v
+	}
+
+	v.Rounds, v.Ticks = g.round, g.ticks
+	for _, s := range g.snakes {
+		v.Snakes = append(v.Snakes, snakeResult{Score: s.score, Length: s.body.len(), Dead: s.dead, Cause: s.cause})
+	}
+	if c != nil {
+		v.check(c)
+	}
+	return v
+}
+
+// check compares a claim with how the replayed game really went.
+func (v *verdict) check(c *claim) {
+	s := v.Snakes[0]
+	if c.Score != s.Score {
+		v.problem("claims a score of %d, the replay scores %d", c.Score, s.Score)
+	}
+	if c.Length != s.Length {
+		v.problem("claims a length of %d, the replay ends at %d", c.Length, s.Length)
+	}
+	if c.Ticks != v.Ticks {
+		v.problem("claims %d ticks, the replay lasts %d", c.Ticks, v.Ticks)
+	}
+}
+
+func runVerify(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	score := fs.Int("score", -1, "claimed score for player 1")
+	length := fs.Int("length", -1, "claimed length for player 1")
+	ticks := fs.Int("ticks", -1, "claimed number of ticks")
+	path := parseReplayArgs(fs, args)
+	if path == "" || fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "usage: gsnake verify REPLAY [--score N] [--length N] [--ticks N]")
+		os.Exit(2)
+	}
+	r, err := loadReplay(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+
+	// Claims not given are taken to be whatever the replay says, so the
+	// replay alone can still be checked for illegal moves.
+	v := verifyReplay(r, nil)
+	if v.Snakes != nil {
+		c := &claim{Score: v.Snakes[0].Score, Length: v.Snakes[0].Length, Ticks: v.Ticks}
+		if *score >= 0 {
+			c.Score = *score
+		}
+		if *length >= 0 {
+			c.Length = *length
+		}
+		if *ticks >= 0 {
+			c.Ticks = *ticks
+		}
+		v.check(c)
+	}
+
+	if v.Snakes != nil {
+		fmt.Printf("%d round(s), last one %d ticks\n", v.Rounds, v.Ticks)
+		for i, s := range v.Snakes {
+			end := "alive"
+			if s.Dead {
+				end = causeText[s.Cause]
+			}
+			fmt.Printf("P%d: score %d, length %d, %s\n", i+1, s.Score, s.Length, end)
+		}
+	}
+	if !v.ok() {
+		for _, p := range v.Problems {
+			fmt.Println("FAIL:", p)
+		}
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
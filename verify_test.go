@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyRecordedGame(t *testing.T) {
+	// record presses keys after the game is over, which an honest replay
+	// still verifies with.
+	g := record(t, 1, 5)
+	s := g.snakes[0]
+	c := &claim{Score: s.score, Length: s.body.len(), Ticks: g.ticks}
+	if v := verifyReplay(g.log, c); !v.ok() {
+		t.Errorf("claiming what was played fails verification: %v", v.Problems)
+	}
+}
+
+func TestVerifyReversal(t *testing.T) {
+	// Snakes start heading right, so turning left on the first tick is
+	// straight back into the neck.
+	r := &replay{
+		Version: replayVersion, Seed: 1, Width: 20, Height: 12, Players: 1, Food: 1, Rounds: 1,
+		Moves:    []replayMove{{Round: 1, Tick: 0, Dir: "left"}},
+		EndRound: 1, EndTick: 3,
+	}
+	v := verifyReplay(r, nil)
+	if len(v.Problems) != 1 || !strings.Contains(v.Problems[0], "reverses") {
+		t.Errorf("forged reversal gives %q, want it flagged as one", v.Problems)
+	}
+}
+
+func TestVerifyWrongClaims(t *testing.T) {
+	g := record(t, 1, 6)
+	s := g.snakes[0]
+	honest := claim{Score: s.score, Length: s.body.len(), Ticks: g.ticks}
+	for _, tc := range []struct {
+		lie  func(c *claim)
+		want string
+	}{
+		{func(c *claim) { c.Score++ }, "claims a score of"},
+		{func(c *claim) { c.Length += 2 }, "claims a length of"},
+		{func(c *claim) { c.Ticks-- }, "ticks, the replay lasts"},
+	} {
+		c := honest
+		tc.lie(&c)
+		v := verifyReplay(g.log, &c)
+		if len(v.Problems) != 1 || !strings.Contains(v.Problems[0], tc.want) {
+			t.Errorf("claiming %+v of a game that went %+v gives %q, want one problem saying %q", c, honest, v.Problems, tc.want)
+		}
+	}
+}
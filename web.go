@@ -0,0 +1,99 @@
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+)
+
+//go:embed web.html
+var webPage string
+
+var webTemplate = template.Must(template.New("web").Parse(webPage))
+
+// webLook is what the page needs to draw a game the way the terminal does.
+type webLook struct {
+	Background string            `json:"background"`
+	Wall       string            `json:"wall"`
+	Food       string            `json:"food"`
+	Dead       string            `json:"dead"`
+	Snakes     []string          `json:"snakes"`
+	Causes     map[string]string `json:"causes"`
+}
+
+func lookOf(t *Theme) webLook {
+	l := webLook{
+		Background: hexColor(t.Background),
+		Wall:       hexColor(t.Wall),
+		Food:       hexColor(t.Food),
+		Dead:       hexColor(t.Dead),
+		Causes:     causeText,
+	}
+	for _, c := range t.Snakes {
+		l.Snakes = append(l.Snakes, hexColor(c))
+	}
+	return l
+}
+
+// webHandler serves the browser client and the WebSockets it plays over.
+// /ws/lobby joins the shared lobby on the given server; /ws/solo gets a
+// private server from solo, for one player, gone when they are. Both speak
+// the protocol in PROTOCOL.md, one message per WebSocket text message.
+func webHandler(lobby *server, solo func() *server, look webLook) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		webTemplate.Execute(w, look)
+	})
+	mux.HandleFunc("GET /ws/lobby", func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgradeWS(w, r)
+		if err != nil {
+			return
+		}
+		handshake(c, lobby.events)
+	})
+	mux.HandleFunc("GET /ws/solo", func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgradeWS(w, r)
+		if err != nil {
+			return
+		}
+		s := solo()
+		stop := make(chan error, 1)
+		go s.run(stop)
+		if !handshake(c, s.events) {
+			stop <- nil
+		}
+	})
+	return mux
+}
+
+func runWeb(args []string) {
+	cfg := mustLoadConfig()
+	fs := flag.NewFlagSet("web", flag.ExitOnError)
+	listen := fs.String("listen", ":8080", "`address` to serve the browser client on")
+	themeName := fs.String("theme", cfg.Theme, "colors to draw in: "+themeNames())
+	match := matchFlags(fs)
+	fs.Parse(args)
+	t, ok := themes[*themeName]
+	if !ok {
+		fmt.Fprintln(os.Stderr, "gsnake: unknown --theme", *themeName)
+		os.Exit(2)
+	}
+	lobby := match()
+	solo := func() *server {
+		s := newServer(1, 1, 1, lobby.foodCount, lobby.tick)
+		s.private = true
+		return s
+	}
+
+	go lobby.run(nil)
+	log.Printf("serving the browser client on %s", *listen)
+	if err := http.ListenAndServe(*listen, webHandler(lobby, solo, lookOf(t))); err != nil {
+		fmt.Fprintln(os.Stderr, "gsnake:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startWeb serves gsnake web's handler on localhost until the test ends and
+// returns its ws:// URL.
+func startWeb(t *testing.T, tick time.Duration) string {
+	t.Helper()
+	lobby := newServer(2, 4, 3, 1, tick)
+	stop := make(chan error, 1)
+	go lobby.run(stop)
+	solo := func() *server {
+		s := newServer(1, 1, 1, 1, tick)
+		s.private = true
+		return s
+	}
+	srv := httptest.NewServer(webHandler(lobby, solo, lookOf(themes["classic"])))
+	t.Cleanup(func() {
+		srv.Close()
+		stop <- nil
+	})
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// writeRaw sends one masked frame the way a browser would, fin and all,
+// for what wsConn never sends itself. The payload must be under 126 bytes.
+func writeRaw(t *testing.T, c *wsConn, fin bool, op byte, payload []byte) {
+	t.Helper()
+	head := []byte{op, 0x80 | byte(len(payload))}
+	if fin {
+		head[0] |= 0x80
+	}
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	frame := append(head, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expect reads c's messages until one of type typ that ok accepts. A nil
+// ok accepts any.
+func expect(t *testing.T, c *wsConn, typ string, ok func(*message) bool) *message {
+	t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		m, err := c.recv()
+		if err != nil {
+			t.Fatalf("waiting for a %s message: %v", typ, err)
+		}
+		if m.Type == typ && (ok == nil || ok(m)) {
+			return m
+		}
+	}
+}
+
+func TestWebSolo(t *testing.T) {
+	c, err := dialWS(startWeb(t, 10*time.Millisecond) + "/ws/solo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.conn.Close()
+
+	if err := c.send(&message{Type: msgHello, Version: protocolVersion, Name: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	id := expect(t, c, msgWelcome, nil).ID
+	expect(t, c, msgLobby, nil)
+
+	// The ready arrives in two fragments with a ping between them, which
+	// control frames are allowed to interrupt.
+	ready, err := json.Marshal(&message{Type: msgReady, Ready: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	half := len(ready) / 2
+	writeRaw(t, c, false, wsText, ready[:half])
+	writeRaw(t, c, true, wsPing, []byte("still there?"))
+	writeRaw(t, c, true, wsContinuation, ready[half:])
+
+	st := expect(t, c, msgState, nil).State
+	if len(st.Snakes) != 1 || st.Snakes[0].ID != id {
+		t.Fatalf("solo game started with %+v, want alice's snake alone", st.Snakes)
+	}
+	if err := c.send(&message{Type: msgDir, Dir: "down"}); err != nil {
+		t.Fatal(err)
+	}
+	tick := expect(t, c, msgTick, func(m *message) bool { return m.Tick.Over }).Tick
+	if !tick.MatchOver || tick.Snakes[0].Cause != causeWall {
+		t.Errorf("solo game ended with match over %v, cause %q; want true and %q", tick.MatchOver, tick.Snakes[0].Cause, causeWall)
+	}
+
+	// A close is answered with one, and then the server hangs up.
+	writeRaw(t, c, true, wsClose, nil)
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	closed := false
+	for {
+		_, op, _, err := c.readFrame()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				t.Errorf("after the close handshake: %v, want the connection closed", err)
+			}
+			break
+		}
+		closed = closed || op == wsClose
+	}
+	if !closed {
+		t.Error("the server never answered the close")
+	}
+}
+
+func TestWebLobbyOverWebSocket(t *testing.T) {
+	// A terminal client joins a browser lobby through dial, as gsnake join
+	// ws://... does.
+	c, err := dial(startWeb(t, 50*time.Millisecond)+"/ws/lobby", "bob", rolePlayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.w.close()
+	ws := c.w.(*wsConn)
+	lobby := expect(t, ws, msgLobby, nil).Lobby
+	if len(lobby.Players) != 1 || lobby.Players[0].ID != c.id || lobby.Players[0].Name != "bob" {
+		t.Errorf("lobby has %+v, want bob alone", lobby.Players)
+	}
+}
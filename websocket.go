@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// wsConn carries protocol messages over a WebSocket, one JSON object per
+// text message, so browsers can speak the same protocol as gsnake join.
+// Only what the protocol needs is implemented: text messages, ping and
+// close, no extensions.
+type wsConn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	client bool
+	wmu    sync.Mutex
+	// msg collects a message sent in fragments.
+	msg []byte
+}
+
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+// wsMaxMessage is far more than any protocol message needs; anything bigger
+// is someone misbehaving.
+const wsMaxMessage = 1 << 20
+
+// wsGUID is the fixed string RFC 6455 mixes into the handshake.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+func wsAccept(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func headerHas(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgradeWS takes over an HTTP request asking for a WebSocket. On failure
+// it has already answered the request.
+func upgradeWS(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerHas(r.Header, "Connection", "upgrade") || !headerHas(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "this is a WebSocket endpoint", http.StatusBadRequest)
+		return nil, errors.New("not a WebSocket request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("unsupported WebSocket version")
+	}
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "can't upgrade this connection", http.StatusInternalServerError)
+		return nil, err
+	}
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", wsAccept(key))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, r: rw.Reader}, nil
+}
+
+// dialWS opens a WebSocket to a ws:// URL, such as the /ws/ endpoints of
+// gsnake web.
+func dialWS(rawURL string) (*wsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "ws" {
+		return nil, fmt.Errorf("%s: only ws:// URLs are supported", rawURL)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "80")
+	}
+	conn, err := net.Dial("tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req := &http.Request{Method: http.MethodGet, URL: u, Header: http.Header{}, Host: u.Host}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	fail := func(err error) (*wsConn, error) {
+		conn.Close()
+		return nil, err
+	}
+	if err := req.Write(conn); err != nil {
+		return fail(err)
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return fail(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return fail(fmt.Errorf("%s answered %s, not a WebSocket", rawURL, resp.Status))
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != wsAccept(key) {
+		return fail(fmt.Errorf("%s got the WebSocket handshake wrong", rawURL))
+	}
+	return &wsConn{conn: conn, r: r, client: true}, nil
+}
+
+// writeFrame sends one unfragmented frame. Clients have to mask what they
+// send, servers must not.
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	head := []byte{0x80 | op, 0}
+	switch n := len(payload); {
+	case n < 126:
+		head[1] = byte(n)
+	case n <= 0xffff:
+		head[1] = 126
+		head = binary.BigEndian.AppendUint16(head, uint16(n))
+	default:
+		head[1] = 127
+		head = binary.BigEndian.AppendUint64(head, uint64(n))
+	}
+	if c.client {
+		head[1] |= 0x80
+		mask := make([]byte, 4)
+		rand.Read(mask)
+		head = append(head, mask...)
+		masked := make([]byte, len(payload))
+		for i, b := range payload {
+			masked[i] = b ^ mask[i%4]
+		}
+		payload = masked
+	}
+	_, err := c.conn.Write(append(head, payload...))
+	return err
+}
+
+// readFrame reads one frame, unmasking it.
+func (c *wsConn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(c.r, head[:]); err != nil {
+		return
+	}
+	fin, op = head[0]&0x80 != 0, head[0]&0x0f
+	masked := head[1]&0x80 != 0
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.r, ext[:]); err != nil {
+			return
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.r, ext[:]); err != nil {
+			return
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > wsMaxMessage {
+		return false, 0, nil, errors.New("WebSocket frame too large")
+	}
+	if masked == c.client {
+		return false, 0, nil, errors.New("WebSocket frame masked the wrong way")
+	}
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.r, mask[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(c.r, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, op, payload, nil
+}
+
+// readMessage returns the next text message, answering pings and closes on
+// the way.
+func (c *wsConn) readMessage() ([]byte, error) {
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case wsPing:
+			c.writeFrame(wsPong, payload)
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			c.writeFrame(wsClose, nil)
+			return nil, io.EOF
+		case wsText, wsBinary:
+			c.msg = c.msg[:0]
+		case wsContinuation:
+		default:
+			return nil, fmt.Errorf("unknown WebSocket opcode %d", op)
+		}
+		c.msg = append(c.msg, payload...)
+		if len(c.msg) > wsMaxMessage {
+			return nil, errors.New("WebSocket message too large")
+		}
+		if fin {
+			return c.msg, nil
+		}
+	}
+}
+
+func (c *wsConn) recv() (*message, error) {
+	data, err := c.readMessage()
+	if err != nil {
+		return nil, err
+	}
+	var m message
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (c *wsConn) send(m *message) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(wsText, data)
+}
+
+func (c *wsConn) close() error {
+	c.writeFrame(wsClose, nil)
+	return c.conn.Close()
+}